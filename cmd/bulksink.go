@@ -0,0 +1,317 @@
+package reader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+)
+
+// StatementSink is a Sink which receives the statements along with the tables and rows
+// they are about, instead of just the sql, i.e. to load the inserted rows in bulk.
+// WriteStatements is called in place of Write.
+type StatementSink interface {
+	Sink
+	// WriteStatements receives the statements of one or more oplogs, grouped per oplog
+	// unless the statements across them are merged, see WithInsertBatching and WithCompaction
+	WriteStatements(batch [][]parser.Statement) error
+}
+
+// CopySink writes a psql script in which the inserts are gathered into a COPY ... FROM STDIN
+// block per table, which loads much faster than the inserts. Statements about different tables
+// can be reordered, so the inserts and ddl of the nested objects don't split the block of their
+// parent table, which ends only on another statement about the same table or when its columns
+// change. Other statements are written as they are. COPY fails on duplicate keys, so it is
+// meant for the initial load of the tables, not for replaying.
+// Blocks don't span the batches handed over to the sink, see WithBatchSize.
+type CopySink struct {
+	w io.Writer
+}
+
+// rows of a COPY block along with the insert whose table and columns it holds
+type copyBlock struct {
+	insert *parser.Statement
+	rows strings.Builder
+}
+
+func NewCopySink(w io.Writer) *CopySink {
+	return &CopySink{w: w}
+}
+
+func(cs *CopySink) Write(batch [][]string) error {
+	for _, stmts := range batch {
+		for _, stmt := range stmts {
+			if _, err := io.WriteString(cs.w, stmt + "\n"); err != nil {
+				return fmt.Errorf("error while writing sql: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+func(cs *CopySink) WriteStatements(batch [][]parser.Statement) error {
+	var b strings.Builder
+	var blocks []*copyBlock		// blocks being gathered, in the order of their tables
+	for _, stmts := range batch {
+		for i := range stmts {
+			stmt := &stmts[i]
+			j := slices.IndexFunc(blocks, func(block *copyBlock) bool { return sameTable(block.insert, stmt) })
+			if j >= 0 && !sameInsert(blocks[j].insert, stmt) {
+				blocks[j].writeTo(&b)
+				blocks = slices.Delete(blocks, j, j+1)
+				j = -1
+			}
+			if stmt.Kind != parser.Insert {
+				b.WriteString(stmt.SQL + "\n")
+				continue
+			}
+			if j < 0 {
+				blocks = append(blocks, &copyBlock{insert: stmt})
+				j = len(blocks) - 1
+			}
+			for _, row := range stmt.Rows {
+				vals := make([]string, 0, len(row))
+				for _, val := range row {
+					vals = append(vals, copyValue(val))
+				}
+				blocks[j].rows.WriteString(strings.Join(vals, "\t") + "\n")
+			}
+		}
+	}
+	for _, block := range blocks {
+		block.writeTo(&b)
+	}
+
+	if _, err := io.WriteString(cs.w, b.String()); err != nil {
+		return fmt.Errorf("error while writing sql: %v", err)
+	}
+	return nil
+}
+
+func(cs *CopySink) Close() error {
+	return nil
+}
+
+func(cb *copyBlock) writeTo(b *strings.Builder) {
+	fmt.Fprintf(b, "COPY %s.%s (%s) FROM STDIN;\n", cb.insert.DBName, cb.insert.TableName, strings.Join(cb.insert.Columns, ", "))
+	b.WriteString(cb.rows.String())
+	b.WriteString("\\.\n")
+}
+
+// CSVSink writes the inserted rows to csv files in a directory, along with a manifest.json
+// listing the steps to load them in order. Inserts go to a file per table, which ends the same
+// way as a block of CopySink, and the other statements, i.e. the ddl, updates and deletes,
+// are listed as sql in between the files, so that loading the files and running the sql in
+// the order of the manifest gives the same tables as running the statements.
+// Manifest is written on Close.
+type CSVSink struct {
+	dir string
+	manifest CSVManifest
+	files map[string]int		// number of files written so far per table
+	open []*csvFile				// files being written to, in the order of their tables
+}
+
+// CSVManifest lists the steps to load the output of CSVSink
+type CSVManifest struct {
+	Steps []CSVStep `json:"steps"`
+}
+
+// CSVStep is either a sql statement to run, or a csv file to load into a table
+type CSVStep struct {
+	SQL string `json:"sql,omitempty"`
+	DBName string `json:"db,omitempty"`
+	TableName string `json:"table,omitempty"`
+	File string `json:"file,omitempty"`
+	Columns []string `json:"columns,omitempty"`
+	Types []string `json:"types,omitempty"`
+	Rows int `json:"rows,omitempty"`
+}
+
+// csv file along with the insert whose table and columns it holds
+type csvFile struct {
+	f *os.File
+	insert *parser.Statement
+	step int		// index of its step in the manifest
+}
+
+// NewCSVSink returns a sink writing to the given directory, which is created if needed
+func NewCSVSink(dir string) (*CSVSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error while creating csv directory: %v", err)
+	}
+	return &CSVSink{dir: dir, files: make(map[string]int)}, nil
+}
+
+// Write lists the statements as sql, as there are no rows to write to the files
+func(cs *CSVSink) Write(batch [][]string) error {
+	if err := cs.closeFiles(); err != nil {
+		return err
+	}
+	for _, stmts := range batch {
+		for _, stmt := range stmts {
+			cs.manifest.Steps = append(cs.manifest.Steps, CSVStep{SQL: stmt})
+		}
+	}
+	return nil
+}
+
+func(cs *CSVSink) WriteStatements(batch [][]parser.Statement) error {
+	for _, stmts := range batch {
+		for i := range stmts {
+			if err := cs.writeStatement(&stmts[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func(cs *CSVSink) writeStatement(stmt *parser.Statement) error {
+	i := slices.IndexFunc(cs.open, func(file *csvFile) bool { return sameTable(file.insert, stmt) })
+	if i >= 0 && !sameInsert(cs.open[i].insert, stmt) {
+		if err := cs.closeFile(i); err != nil {
+			return err
+		}
+		i = -1
+	}
+	if stmt.Kind != parser.Insert {
+		cs.manifest.Steps = append(cs.manifest.Steps, CSVStep{SQL: stmt.SQL})
+		return nil
+	}
+	if i < 0 {
+		if err := cs.openFile(stmt); err != nil {
+			return err
+		}
+		i = len(cs.open) - 1
+	}
+	file := cs.open[i]
+
+	var b strings.Builder
+	for _, row := range stmt.Rows {
+		vals := make([]string, 0, len(row))
+		for _, val := range row {
+			vals = append(vals, csvValue(val))
+		}
+		b.WriteString(strings.Join(vals, ",") + "\n")
+	}
+	if _, err := file.f.WriteString(b.String()); err != nil {
+		return fmt.Errorf("error while writing csv: %v", err)
+	}
+	cs.manifest.Steps[file.step].Rows += len(stmt.Rows)
+	return nil
+}
+
+// starts a new file for the table of the insert, named <db>.<table>.<n>.csv
+func(cs *CSVSink) openFile(stmt *parser.Statement) error {
+	table := stmt.DBName + "." + stmt.TableName
+	cs.files[table]++
+	name := fmt.Sprintf("%s.%d.csv", table, cs.files[table])
+
+	f, err := os.Create(filepath.Join(cs.dir, name))
+	if err != nil {
+		return fmt.Errorf("error while creating csv file: %v", err)
+	}
+	if _, err := f.WriteString(strings.Join(stmt.Columns, ",") + "\n"); err != nil {
+		f.Close()
+		return fmt.Errorf("error while writing csv: %v", err)
+	}
+
+	cs.manifest.Steps = append(cs.manifest.Steps, CSVStep{
+		DBName: stmt.DBName,
+		TableName: stmt.TableName,
+		File: name,
+		Columns: stmt.Columns,
+		Types: stmt.Types,
+	})
+	cs.open = append(cs.open, &csvFile{f: f, insert: stmt, step: len(cs.manifest.Steps) - 1})
+	return nil
+}
+
+// closes the i-th of the files being written to
+func(cs *CSVSink) closeFile(i int) error {
+	err := cs.open[i].f.Close()
+	cs.open = slices.Delete(cs.open, i, i+1)
+	if err != nil {
+		return fmt.Errorf("error while closing csv file: %v", err)
+	}
+	return nil
+}
+
+// closes all the files being written to
+func(cs *CSVSink) closeFiles() error {
+	for len(cs.open) > 0 {
+		if err := cs.closeFile(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the files being written to and writes the manifest
+func(cs *CSVSink) Close() error {
+	if err := cs.closeFiles(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cs.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while encoding manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cs.dir, "manifest.json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error while writing manifest: %v", err)
+	}
+	return nil
+}
+
+// checks if the statement is about the same table as the given insert
+func sameTable(insert, stmt *parser.Statement) bool {
+	return stmt.DBName == insert.DBName && stmt.TableName == insert.TableName
+}
+
+// checks if the statement inserts into the same table and columns as the given insert
+func sameInsert(insert, stmt *parser.Statement) bool {
+	return stmt.Kind == parser.Insert && sameTable(insert, stmt) && slices.Equal(stmt.Columns, insert.Columns)
+}
+
+// formats the value for the text format of COPY, escaping the backslash and the delimiters
+func copyValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "\\N"
+	case string:
+		return strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r").Replace(v)
+	default:
+		return plainValue(v)
+	}
+}
+
+// formats the value for csv, strings are always quoted so that an empty string
+// is told apart from NULL, which is left empty
+func csvValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return `"` + strings.ReplaceAll(v, `"`, `""`) + `"`
+	default:
+		return plainValue(v)
+	}
+}
+
+// formats the numbers and booleans the same way as the parser does
+func plainValue(val interface{}) string {
+	switch v := val.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
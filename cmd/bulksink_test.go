@@ -0,0 +1,139 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+)
+
+const bulkOplogs = `
+	{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena\tMiller", "roll_no": 51}}
+	{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George \"Smith\"", "roll_no": 52}}
+	{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 53}}}, "o2": {"_id": "2"}}
+	{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "", "roll_no": 54, "address": [{"zip": "89799"}, {"zip": "12345"}]}}
+`
+
+func TestReadFromToCopySink(t *testing.T) {
+	exp := strings.Join([]string{
+		"CREATE SCHEMA test;",
+		"CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), roll_no  FLOAT);",
+		"COPY test.student (_id, name, roll_no) FROM STDIN;",
+		"1\tSelena\\tMiller\t51",
+		"2\tGeorge \"Smith\"\t52",
+		"\\.",
+		"UPDATE test.student SET roll_no = 53 WHERE _id = '2';",
+		"CREATE TABLE test.student_address (_id  VARCHAR(255) PRIMARY KEY, student__id  VARCHAR(255), zip  VARCHAR(255));",
+		"COPY test.student (_id, name, roll_no) FROM STDIN;",
+		"3\t\t54",
+		"\\.",
+		"COPY test.student_address (_id, student__id, zip) FROM STDIN;",
+		"",
+	}, "\n")
+
+	var out bytes.Buffer
+	err := ReadFrom(strings.NewReader(bulkOplogs), NewCopySink(&out), WithBatchSize(10))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// ids of the nested object rows are random, so only the start of the output is compared
+	got := out.String()
+	if !strings.HasPrefix(got, exp) {
+		t.Fatalf("Expected output to start with %q but got %q", exp, got)
+	}
+	rows := strings.Split(strings.TrimPrefix(got, exp), "\n")
+	if len(rows) != 4 || !strings.HasSuffix(rows[0], "\t3\t89799") || !strings.HasSuffix(rows[1], "\t3\t12345") || rows[2] != "\\." {
+		t.Errorf("Expected two rows for the nested objects but got %q", rows)
+	}
+}
+
+func TestReadFromToCSVSink(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	sink, err := NewCSVSink(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err = ReadFrom(strings.NewReader(bulkOplogs), sink, WithParserOptions(parser.WithDialect(parser.SQLite)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("Error while reading manifest: %v", err)
+	}
+	var manifest CSVManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Error while decoding manifest: %v", err)
+	}
+
+	expSteps := []CSVStep{
+		{SQL: "CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), roll_no  FLOAT);"},
+		{DBName: "test", TableName: "student", File: "test.student.1.csv", Columns: []string{"_id", "name", "roll_no"}, Types: []string{"VARCHAR(255)", "VARCHAR(255)", "FLOAT"}, Rows: 2},
+		{SQL: "UPDATE test_student SET roll_no = 53 WHERE _id = '2';"},
+		{DBName: "test", TableName: "student", File: "test.student.2.csv", Columns: []string{"_id", "name", "roll_no"}, Types: []string{"VARCHAR(255)", "VARCHAR(255)", "FLOAT"}, Rows: 1},
+		{SQL: "CREATE TABLE test_student_address (_id  VARCHAR(255) PRIMARY KEY, student__id  VARCHAR(255), zip  VARCHAR(255));"},
+		{DBName: "test", TableName: "student_address", File: "test.student_address.1.csv", Columns: []string{"_id", "student__id", "zip"}, Types: []string{"VARCHAR(255)", "VARCHAR(255)", "VARCHAR(255)"}, Rows: 2},
+	}
+	if !reflect.DeepEqual(expSteps, manifest.Steps) {
+		t.Errorf("Expected steps %+v but got %+v", expSteps, manifest.Steps)
+	}
+
+	expFiles := map[string]string{
+		"test.student.1.csv": "_id,name,roll_no\n\"1\",\"Selena\tMiller\",51\n\"2\",\"George \"\"Smith\"\"\",52\n",
+		"test.student.2.csv": "_id,name,roll_no\n\"3\",\"\",54\n",
+	}
+	for name, exp := range expFiles {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Error while reading %s: %v", name, err)
+		}
+		if string(data) != exp {
+			t.Errorf("Expected %s to be %q but got %q", name, exp, data)
+		}
+	}
+}
+
+// inserts of the nested objects come in between the ones of their parent, yet each table
+// gets a single block or file
+func TestBulkSinksNestedObjects(t *testing.T) {
+	inputFile := "../testdata/nested/input.json"
+
+	var out bytes.Buffer
+	if err := ReadToSink(inputFile, NewCopySink(&out), WithBatchSize(100)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, table := range []string{"test.student", "test.student_phone", "test.student_address"} {
+		if n := strings.Count(out.String(), "COPY " + table + " "); n != 1 {
+			t.Errorf("Expected 1 COPY block for %s but got %d in %q", table, n, out.String())
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "out")
+	sink, err := NewCSVSink(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := ReadToSink(inputFile, sink, WithBatchSize(100)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		t.Fatalf("Error while listing csv files: %v", err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.Base(f))
+	}
+	exp := []string{"test.student.1.csv", "test.student_address.1.csv", "test.student_phone.1.csv"}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("Expected files %q but got %q", exp, got)
+	}
+}
@@ -0,0 +1,206 @@
+package reader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+)
+
+// CDCSink writes a change event for each inserted, updated or deleted row, one json object
+// per line, in the envelope used by Debezium, i.e. before, after, op, source and ts_ms.
+// Inserts are c events with the row in after, deletes are d events with the condition
+// in before, and updates are u events with just the condition and the updated columns
+// in after, unset ones being null, as the rest of the row isn't known from the oplog.
+// DDL statements have no events.
+type CDCSink struct {
+	encoder *json.Encoder
+	withSchema bool
+	now func() time.Time
+}
+
+// CDCSinkOption configures the behaviour of CDCSink
+type CDCSinkOption func(*CDCSink)
+
+// WithCDCSchema makes CDCSink wrap each event along with its schema, as the json converter of
+// Kafka Connect does with schemas enabled. Types of the columns are the ones the parser infers
+// for the tables, i.e. FLOAT columns are double fields.
+func WithCDCSchema() CDCSinkOption {
+	return func(cs *CDCSink) {
+		cs.withSchema = true
+	}
+}
+
+func NewCDCSink(w io.Writer, opts ...CDCSinkOption) *CDCSink {
+	cs := &CDCSink{
+		encoder: json.NewEncoder(w),
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(cs)
+	}
+	return cs
+}
+
+// cdcEvent is the payload of a change event
+type cdcEvent struct {
+	Before map[string]interface{} `json:"before"`
+	After map[string]interface{} `json:"after"`
+	Op string `json:"op"`
+	Source cdcSource `json:"source"`
+	TsMs int64 `json:"ts_ms"`
+}
+
+// cdcSource tells where the change comes from, ts_ms and ord are the ts of the oplog
+type cdcSource struct {
+	Connector string `json:"connector"`
+	DB string `json:"db"`
+	Table string `json:"table"`
+	TsMs int64 `json:"ts_ms"`
+	Ord uint32 `json:"ord"`
+}
+
+// cdcMessage is a change event along with its schema
+type cdcMessage struct {
+	Schema cdcSchema `json:"schema"`
+	Payload cdcEvent `json:"payload"`
+}
+
+// cdcSchema describes a field of the event, or the event itself, as per Kafka Connect
+type cdcSchema struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	Optional bool `json:"optional"`
+	Field string `json:"field,omitempty"`
+	Fields []cdcSchema `json:"fields,omitempty"`
+}
+
+// Write has nothing to write, as the events can't be told from the sql alone
+func(cs *CDCSink) Write(batch [][]string) error {
+	return fmt.Errorf("error: change events need the statements, not just the sql")
+}
+
+func(cs *CDCSink) WriteStatements(batch [][]parser.Statement) error {
+	for _, stmts := range batch {
+		for i := range stmts {
+			for _, msg := range cs.getMessages(&stmts[i]) {
+				if err := cs.encoder.Encode(msg); err != nil {
+					return fmt.Errorf("error while writing change event: %v", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func(cs *CDCSink) Close() error {
+	return nil
+}
+
+// returns the events for the statement, wrapped along with the schema if asked for
+func(cs *CDCSink) getMessages(stmt *parser.Statement) []interface{} {
+	source := cdcSource{
+		Connector: "oplog2sql",
+		DB: stmt.DBName,
+		Table: stmt.TableName,
+	}
+	if stmt.HasTs {
+		source.TsMs = int64(stmt.Ts.T) * 1000
+		source.Ord = stmt.Ts.I
+	}
+	event := cdcEvent{Source: source, TsMs: cs.now().UnixMilli()}
+
+	var rows []map[string]interface{}
+	switch stmt.Kind {
+	case parser.Insert:
+		event.Op = "c"
+		for _, row := range stmt.Rows {
+			after := make(map[string]interface{}, len(row))
+			for i, col := range stmt.Columns {
+				after[col] = row[i]
+			}
+			rows = append(rows, after)
+		}
+	case parser.Update:
+		event.Op = "u"
+		after := make(map[string]interface{}, len(stmt.Where) + len(stmt.Set) + len(stmt.Unset))
+		for col, val := range stmt.Where {
+			after[col] = val
+		}
+		for col, val := range stmt.Set {
+			after[col] = val
+		}
+		for _, col := range stmt.Unset {
+			after[col] = nil
+		}
+		rows = append(rows, after)
+	case parser.Delete:
+		event.Op = "d"
+		rows = append(rows, stmt.Where)
+	default:
+		return nil
+	}
+
+	msgs := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		e := event
+		if e.Op == "d" {
+			e.Before = row
+		} else {
+			e.After = row
+		}
+
+		if !cs.withSchema {
+			msgs = append(msgs, e)
+			continue
+		}
+		msgs = append(msgs, cdcMessage{Schema: getCDCSchema(stmt, row), Payload: e})
+	}
+	return msgs
+}
+
+// returns the schema of the event for the row of the table
+func getCDCSchema(stmt *parser.Statement, row map[string]interface{}) cdcSchema {
+	name := stmt.DBName + "." + stmt.TableName
+	var fields []cdcSchema
+	for _, col := range parser.GetSortedKeys(row) {
+		fields = append(fields, cdcSchema{
+			Type: getCDCFieldType(parser.GetColumnType(row[col])),
+			Optional: col != "_id",
+			Field: col,
+		})
+	}
+
+	return cdcSchema{
+		Type: "struct",
+		Name: name + ".Envelope",
+		Fields: []cdcSchema{
+			{Type: "struct", Name: name + ".Value", Optional: true, Field: "before", Fields: fields},
+			{Type: "struct", Name: name + ".Value", Optional: true, Field: "after", Fields: fields},
+			{Type: "string", Field: "op"},
+			{Type: "struct", Name: "oplog2sql.Source", Field: "source", Fields: []cdcSchema{
+				{Type: "string", Field: "connector"},
+				{Type: "string", Field: "db"},
+				{Type: "string", Field: "table"},
+				{Type: "int64", Field: "ts_ms"},
+				{Type: "int32", Field: "ord"},
+			}},
+			{Type: "int64", Optional: true, Field: "ts_ms"},
+		},
+	}
+}
+
+// maps the sql type of the column to the type of the field, null values are
+// typed as strings, as nothing else is known about them
+func getCDCFieldType(colType string) string {
+	switch colType {
+	case "FLOAT":
+		return "double"
+	case "BOOLEAN":
+		return "boolean"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,81 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+)
+
+func TestReadFromToCDCSink(t *testing.T) {
+	input := `
+		{"op": "i", "ns": "test.student", "ts": {"$timestamp": {"t": 1700000000, "i": 1}}, "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51, "address": {"zip": "89799"}}}
+		{"op": "u", "ns": "test.student", "ts": {"$timestamp": {"t": 1700000001, "i": 2}}, "o": {"$v": 2, "diff": {"u": {"roll_no": 52}, "d": {"name": false}}}, "o2": {"_id": "1"}}
+		{"op": "d", "ns": "test.student", "o": {"_id": "1"}}
+	`
+	exp := []string{
+		`{"before":null,"after":{"_id":"1","name":"Selena Miller","roll_no":51},"op":"c","source":{"connector":"oplog2sql","db":"test","table":"student","ts_ms":1700000000000,"ord":1},"ts_ms":1700000005000}`,
+		`{"before":null,"after":{"_id":"a483fce5044e155981bb268b","student__id":"1","zip":"89799"},"op":"c","source":{"connector":"oplog2sql","db":"test","table":"student_address","ts_ms":1700000000000,"ord":1},"ts_ms":1700000005000}`,
+		`{"before":null,"after":{"_id":"1","name":null,"roll_no":52},"op":"u","source":{"connector":"oplog2sql","db":"test","table":"student","ts_ms":1700000001000,"ord":2},"ts_ms":1700000005000}`,
+		`{"before":{"_id":"1"},"after":null,"op":"d","source":{"connector":"oplog2sql","db":"test","table":"student","ts_ms":0,"ord":0},"ts_ms":1700000005000}`,
+	}
+
+	var out bytes.Buffer
+	sink := NewCDCSink(&out)
+	sink.now = func() time.Time { return time.Unix(1700000005, 0) }
+
+	// ids of the nested object rows are derived from the parent _id in idempotent mode
+	err := ReadFrom(strings.NewReader(input), sink, WithParserOptions(parser.WithIdempotent()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(got) != len(exp) {
+		t.Fatalf("Expected %d events but got %q", len(exp), got)
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("Expected %s but got %s", exp[i], got[i])
+		}
+	}
+}
+
+func TestCDCSinkWithSchema(t *testing.T) {
+	input := `{"op": "i", "ns": "test.student", "o": {"_id": "1", "roll_no": 51, "is_graduated": false}}`
+
+	var out bytes.Buffer
+	err := ReadFrom(strings.NewReader(input), NewCDCSink(&out, WithCDCSchema()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var msg struct {
+		Schema cdcSchema `json:"schema"`
+		Payload cdcEvent `json:"payload"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &msg); err != nil {
+		t.Fatalf("Error while decoding event: %v", err)
+	}
+	if msg.Schema.Name != "test.student.Envelope" || msg.Payload.Op != "c" {
+		t.Errorf("Expected insert event of test.student but got %s", out.String())
+	}
+
+	after := msg.Schema.Fields[1]
+	exp := []cdcSchema{
+		{Type: "string", Field: "_id"},
+		{Type: "boolean", Optional: true, Field: "is_graduated"},
+		{Type: "double", Optional: true, Field: "roll_no"},
+	}
+	if after.Field != "after" || len(after.Fields) != len(exp) {
+		t.Fatalf("Expected fields %+v for after but got %+v", exp, after)
+	}
+	for i := range exp {
+		if after.Fields[i].Type != exp[i].Type || after.Fields[i].Field != exp[i].Field || after.Fields[i].Optional != exp[i].Optional {
+			t.Errorf("Expected field %+v but got %+v", exp[i], after.Fields[i])
+		}
+	}
+}
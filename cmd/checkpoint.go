@@ -0,0 +1,92 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// checkpoint holds the timestamp of the last oplog which was handled
+// it is saved in the same extended json format which is used by the oplog
+// along with the resume token if the oplog was a change stream event
+type checkpoint struct {
+	Ts extendedTimestamp `json:"ts"`
+	ResumeToken json.RawMessage `json:"resumeToken,omitempty"`
+}
+
+type extendedTimestamp struct {
+	Timestamp struct {
+		T uint32 `json:"t"`
+		I uint32 `json:"i"`
+	} `json:"$timestamp"`
+}
+
+func(c checkpoint) timestamp() primitive.Timestamp {
+	return primitive.Timestamp{T: c.Ts.Timestamp.T, I: c.Ts.Timestamp.I}
+}
+
+// loads the checkpoint, found is false if no checkpoint has been saved yet
+func loadCheckpoint(checkpointFile string) (c checkpoint, found bool, err error) {
+	data, err := os.ReadFile(checkpointFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, false, nil
+		}
+		return c, false, fmt.Errorf("error while reading checkpoint file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, false, fmt.Errorf("error while decoding checkpoint file: %v", err)
+	}
+
+	// resume token is compacted, so that it can be compared with the ones of the events
+	if c.ResumeToken != nil {
+		var token bytes.Buffer
+		if err := json.Compact(&token, c.ResumeToken); err != nil {
+			return c, false, fmt.Errorf("error while decoding checkpoint file: %v", err)
+		}
+		c.ResumeToken = token.Bytes()
+	}
+	return c, true, nil
+}
+
+// saves the checkpoint by replacing the file, so that a crash midway
+// never leaves a partially written checkpoint behind
+func saveCheckpoint(checkpointFile string, ts primitive.Timestamp, resumeToken json.RawMessage) error {
+	c := checkpoint{ResumeToken: resumeToken}
+	c.Ts.Timestamp.T = ts.T
+	c.Ts.Timestamp.I = ts.I
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("error while encoding checkpoint: %v", err)
+	}
+
+	tmpFile := checkpointFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("error while writing checkpoint file: %v", err)
+	}
+	if err := os.Rename(tmpFile, checkpointFile); err != nil {
+		return fmt.Errorf("error while writing checkpoint file: %v", err)
+	}
+	return nil
+}
+
+// LoadCheckpoint returns the ts saved by WithCheckpoint, found is false if there is none yet.
+// It can be used to start a live source from where the previous run stopped.
+func LoadCheckpoint(checkpointFile string) (ts primitive.Timestamp, found bool, err error) {
+	c, found, err := loadCheckpoint(checkpointFile)
+	return c.timestamp(), found, err
+}
+
+// LoadResumeToken returns the resume token saved by WithCheckpoint along with the ts, which is
+// nil if the last handled oplog was not a change stream event, found is false if there is none yet.
+// A change stream should be resumed after it, as the events of a transaction share the same ts.
+func LoadResumeToken(checkpointFile string) (resumeToken json.RawMessage, found bool, err error) {
+	c, found, err := loadCheckpoint(checkpointFile)
+	return c.ResumeToken, found, err
+}
@@ -1,51 +1,310 @@
-package reader
-
-import (
-    "os"
-	"fmt"
-	"encoding/json"
-
-    "github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
-)
-
-func Read(inputFile, outputFile string) error {
-    // getting file object for the input file
-    inputF, err := os.Open(inputFile)
-    if err != nil {
-		return fmt.Errorf("error while opening file: %v", err)
-    }
-    defer inputF.Close()
-
-    // getting file object for the output file
-    outputF, err := os.Create(outputFile)
-    if err != nil {
-		return fmt.Errorf("error while opening file: %v", err)
-    }
-    defer outputF.Close()
-
-    // decoding the json
-    decoder := json.NewDecoder(inputF)
-    for {
-		var obj json.RawMessage
-        if err := decoder.Decode(&obj); err != nil {
-            if err.Error() == "EOF" {
-                break
-            }
-            return fmt.Errorf("error while decoding json: %v", err)
-        }
-
-        
-        m := parser.NewMongoOplogParser()
-        sqlStmt, err := m.GetEquivalentSQL(string(obj))
-        if err != nil {
-            return fmt.Errorf("error while getting equivalent sql: %v", err)
-        }
-
-        _, err = outputF.WriteString(sqlStmt)
-        if err != nil {
-            fmt.Println("error while writing to file: ", err)
-        }
-    }
-	
-	return nil
-}
\ No newline at end of file
+package reader
+
+import (
+	"context"
+	"errors"
+    "os"
+	"fmt"
+	"io"
+	"time"
+
+    "github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+)
+
+// Stdio can be passed as the input or output file to read from stdin or write to stdout
+const Stdio = "-"
+
+type config struct {
+	deadLetterFile string
+	deadLetterWriter io.Writer
+	checkpointFile string
+	parserOpts []parser.Option
+	batchSize int
+	follow bool
+	pollInterval time.Duration
+	stop <-chan struct{}
+	workers int
+	insertBatchRows int
+	compact bool
+}
+
+// Option configures the behaviour of Read
+type Option func(*config)
+
+// WithDeadLetter makes Read write the oplogs which fail translation to the
+// given JSONL file along with the error, and continue with the next oplog.
+// Input is then read as JSONL, one oplog or array of oplogs per line, so that
+// a line which is not valid json goes to the dead letter as well.
+func WithDeadLetter(deadLetterFile string) Option {
+	return func(c *config) {
+		c.deadLetterFile = deadLetterFile
+	}
+}
+
+// WithDeadLetterWriter is same as WithDeadLetter, but writes to w instead of a file
+func WithDeadLetterWriter(w io.Writer) Option {
+	return func(c *config) {
+		c.deadLetterWriter = w
+	}
+}
+
+// WithCheckpoint makes Read save the ts of the last handled oplog to the given
+// file. If the file already exists, the oplogs up to that ts are skipped and
+// the output is appended to, so that an interrupted conversion can be resumed.
+// Skipped oplogs are still parsed, so that the tables they created aren't created
+// again. Oplogs without ts are always processed.
+func WithCheckpoint(checkpointFile string) Option {
+	return func(c *config) {
+		c.checkpointFile = checkpointFile
+	}
+}
+
+// WithParserOptions passes the given options to the oplog parser,
+// e.g. parser.WithIdempotent() to make the output safe to replay
+func WithParserOptions(opts ...parser.Option) Option {
+	return func(c *config) {
+		c.parserOpts = append(c.parserOpts, opts...)
+	}
+}
+
+// WithBatchSize makes Read hand over the statements of the given number of
+// oplogs to the sink at once, e.g. to execute them in a single transaction.
+// Statements of an oplog are never split across batches. Default is 1.
+func WithBatchSize(batchSize int) Option {
+	return func(c *config) {
+		c.batchSize = batchSize
+	}
+}
+
+// WithFollow makes Read keep reading the oplogs appended to the input file after
+// reaching its end, checking for them every pollInterval, like tail -F. Truncated
+// input is read again from the start and rotated input is followed to the new file.
+// Tables created so far are remembered throughout, and pending statements are
+// handed over to the sink whenever there is nothing more to read.
+// Read returns once stop is closed. It has no effect when reading from stdin.
+func WithFollow(pollInterval time.Duration, stop <-chan struct{}) Option {
+	return func(c *config) {
+		c.follow = true
+		c.pollInterval = pollInterval
+		c.stop = stop
+	}
+}
+
+// WithWorkers makes Read parse the oplogs with the given number of goroutines, while
+// the statements are still handed over to the sink in the order of the oplogs, so the
+// output stays the same as with a single one: statements for the same ns and _id are
+// never reordered and tables are created or altered before they are used. Default is 1.
+func WithWorkers(workers int) Option {
+	return func(c *config) {
+		c.workers = workers
+	}
+}
+
+// WithInsertBatching makes Read merge the consecutive inserts into the same table with the
+// same columns into multi-row inserts of up to the given number of rows, see parser.CoalesceInserts.
+// Inserts are merged only among the statements handed over to the sink at once, so the batch
+// size is raised to the given number if it is smaller, and the statements of a batch are then
+// handed over as a single group instead of one per oplog.
+func WithInsertBatching(maxRows int) Option {
+	return func(c *config) {
+		c.insertBatchRows = maxRows
+		c.parserOpts = append(c.parserOpts, parser.WithInsertBatching(maxRows))
+	}
+}
+
+// WithCompaction makes Read fold the statements for the same row among the statements handed
+// over to the sink at once into their net effect, see parser.CompactStatements, so that a long
+// history replays faster into an empty database. It goes well with a large batch size, and the
+// statements of a batch are then handed over as a single group instead of one per oplog.
+func WithCompaction() Option {
+	return func(c *config) {
+		c.compact = true
+		c.parserOpts = append(c.parserOpts, parser.WithCompaction())
+	}
+}
+
+// Read writes the sql statements equivalent to the oplogs in the input file to the output file
+// Stdio can be used as the input file for stdin and as the output file for stdout
+func Read(inputFile, outputFile string, opts ...Option) error {
+	_, err := ReadContext(context.Background(), inputFile, outputFile, opts...)
+	return err
+}
+
+// ReadContext is same as Read, but stops once ctx is done, see ReadFromSourceContext
+func ReadContext(ctx context.Context, inputFile, outputFile string, opts ...Option) (Progress, error) {
+	cfg := newConfig(opts)
+
+	// output of the previous run is kept intact while resuming
+	_, resume, err := cfg.loadCheckpoint()
+	if err != nil {
+		return Progress{Index: -1}, err
+	}
+
+    // getting file object for the output file
+    outputF, err := OpenOutput(outputFile, os.Stdout, resume)
+    if err != nil {
+		return Progress{Index: -1}, fmt.Errorf("error while opening file: %v", err)
+    }
+    defer outputF.Close()
+
+	return ReadToSinkContext(ctx, inputFile, NewWriterSink(outputF), opts...)
+}
+
+// Convert writes the sql statements equivalent to the oplogs read from r to w
+func Convert(r io.Reader, w io.Writer, opts ...Option) error {
+	return ReadFrom(r, NewWriterSink(w), opts...)
+}
+
+// ConvertContext is same as Convert, but stops once ctx is done, see ReadFromSourceContext
+func ConvertContext(ctx context.Context, r io.Reader, w io.Writer, opts ...Option) (Progress, error) {
+	return ReadFromContext(ctx, r, NewWriterSink(w), opts...)
+}
+
+// ReadToSink hands over the sql statements equivalent to the oplogs in the input file to the sink
+// sink is closed once all the oplogs are handled, Stdio can be used as the input file for stdin
+func ReadToSink(inputFile string, sink Sink, opts ...Option) error {
+	_, err := ReadToSinkContext(context.Background(), inputFile, sink, opts...)
+	return err
+}
+
+// ReadToSinkContext is same as ReadToSink, but stops once ctx is done, see ReadFromSourceContext
+// Following the input file stops once ctx is done as well.
+func ReadToSinkContext(ctx context.Context, inputFile string, sink Sink, opts ...Option) (Progress, error) {
+	cfg := newConfig(opts)
+
+    // getting file object for the input file
+    var inputF io.ReadCloser
+    var err error
+    if cfg.follow && inputFile != Stdio {
+        inputF, err = newFollowReader(inputFile, cfg.pollInterval, cfg.stop, ctx.Done())
+    } else {
+        inputF, err = OpenInput(inputFile, os.Stdin)
+    }
+    if err != nil {
+		sink.Close()
+		return Progress{Index: -1}, fmt.Errorf("error while opening file: %v", err)
+    }
+    defer inputF.Close()
+
+	return ReadFromContext(ctx, inputF, sink, opts...)
+}
+
+// ReadFrom hands over the sql statements equivalent to the oplogs read from r to the sink
+// sink is closed once all the oplogs are handled
+func ReadFrom(r io.Reader, sink Sink, opts ...Option) error {
+	return ReadFromSource(newConfig(opts).newSource(r), sink, opts...)
+}
+
+// ReadFromContext is same as ReadFrom, but stops once ctx is done, see ReadFromSourceContext
+func ReadFromContext(ctx context.Context, r io.Reader, sink Sink, opts ...Option) (Progress, error) {
+	return ReadFromSourceContext(ctx, newConfig(opts).newSource(r), sink, opts...)
+}
+
+// ReadFromSource hands over the sql statements equivalent to the oplogs yielded by src to the sink
+// src and sink are closed once all the oplogs are handled
+func ReadFromSource(src Source, sink Sink, opts ...Option) error {
+	_, err := ReadFromSourceContext(context.Background(), src, sink, opts...)
+	return err
+}
+
+// ReadFromSourceContext is same as ReadFromSource, but stops reading once ctx is done, in which
+// case the statements of the oplogs handled so far are still handed over to the sink and the
+// error of ctx is returned. Progress tells how far it got, also in case of other errors.
+func ReadFromSourceContext(ctx context.Context, src Source, sink Sink, opts ...Option) (progress Progress, err error) {
+	cfg := newConfig(opts)
+	defer func() {
+		// sinks like CSVSink finish writing on close
+		if closeErr := sink.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	defer src.Close()
+
+	c, err := newConverter(cfg, sink)
+	if err != nil {
+		return Progress{Index: -1}, err
+	}
+	defer c.Close()
+
+	// sources which start after the checkpoint on their own yield nothing to skip
+	if r, ok := src.(resumer); ok && r.resumed() {
+		c.resume = false
+	}
+
+	if cfg.workers > 1 {
+		err = c.convertParallel(ctx, src, cfg.workers)
+	} else {
+		err = c.convert(ctx, src)
+	}
+	if err != nil && (ctx.Err() == nil || !errors.Is(err, ctx.Err())) {
+		return c.progress, err
+	}
+
+	// handing over what has been handled, also on cancellation
+	if flushErr := c.flush(); flushErr != nil {
+		return c.progress, flushErr
+	}
+	return c.progress, err
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{batchSize: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.batchSize < 1 {
+		cfg.batchSize = 1
+	}
+	if cfg.batchSize < cfg.insertBatchRows {
+		cfg.batchSize = cfg.insertBatchRows
+	}
+	if cfg.pollInterval <= 0 {
+		cfg.pollInterval = time.Second
+	}
+	return cfg
+}
+
+// returns the source reading the oplogs from r, line by line if the failed ones go to the dead letter
+func(c *config) newSource(r io.Reader) Source {
+	if c.deadLetterFile != "" || c.deadLetterWriter != nil {
+		return newLineSource(r)
+	}
+	return NewJSONSource(r)
+}
+
+// loads the checkpoint if asked for, resume is true if a checkpoint was found
+func(c *config) loadCheckpoint() (cp checkpoint, resume bool, err error) {
+	if c.checkpointFile == "" {
+		return cp, false, nil
+	}
+	return loadCheckpoint(c.checkpointFile)
+}
+
+// OpenInput opens the input file, Stdio stands for stdin, which is not closed along with it
+func OpenInput(inputFile string, stdin io.Reader) (io.ReadCloser, error) {
+	if inputFile == Stdio {
+		return io.NopCloser(stdin), nil
+	}
+	return os.Open(inputFile)
+}
+
+// OpenOutput opens the output file, Stdio stands for stdout, which is not closed along with it
+// Output is appended to if appendOutput is true, i.e. while resuming, instead of being truncated.
+func OpenOutput(outputFile string, stdout io.Writer, appendOutput bool) (io.WriteCloser, error) {
+	if outputFile == Stdio {
+		return nopCloser{stdout}, nil
+	}
+	if appendOutput {
+		return os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	}
+	return os.Create(outputFile)
+}
+
+// nopCloser leaves closing of the writer to its owner
+type nopCloser struct {
+	io.Writer
+}
+
+func(nopCloser) Close() error {
+	return nil
+}
@@ -1,62 +1,541 @@
-package reader
-
-import (
-	"os"
-	"testing"
-
-	pgquery "github.com/pganalyze/pg_query_go/v5"
-)
-
-
-func TestRead(t *testing.T) {
-	inputFile := "../testdata/oplog.json"
-	outputFile := "../testdata/output.sql"
-	exp := `
-			CREATE SCHEMA test;
-			CREATE TABLE test.student
-			(
-				_id           VARCHAR(255) PRIMARY KEY,
-				date_of_birth VARCHAR(255),
-				is_graduated  BOOLEAN,
-				name          VARCHAR(255),
-				roll_no       FLOAT
-			);
-			INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
-			UPDATE test.student SET is_graduated = true WHERE _id = '635b79e231d82a8ab1de863b';
-			UPDATE test.student SET roll_no = NULL WHERE _id = '635b79e231d82a8ab1de863b';
-			DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
-		`
-
-	err := Read(inputFile, outputFile)
-	if err != nil {
-		t.Fatalf("Unexpected error: %v", err)
-	}
-	
-	data, err := os.ReadFile(outputFile)
-	got := string(data)
-	
-	result, err := compareSqlStatement(t, exp, got)
-	if err != nil {
-		t.Fatalf("Error while comparing SQL statements: %v", err)
-	}
-
-	if !result {
-		t.Errorf("Expected %q but got %q", exp, got)
-	}
-}
-
-func compareSqlStatement(t *testing.T, expected, got string) (bool, error) {
-	t.Helper()
-
-	expFp, err := pgquery.Fingerprint(expected)
-	if err != nil {
-		return false, err
-	}
-
-	gotFp, err := pgquery.Fingerprint(got)
-	if err != nil {
-		return false, err
-	}
-
-	return expFp == gotFp, nil
-}
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+	pgquery "github.com/pganalyze/pg_query_go/v5"
+)
+
+
+func TestRead(t *testing.T) {
+	inputFile := "../testdata/oplog.json"
+	outputFile := filepath.Join(t.TempDir(), "output.sql")
+	exp := `
+			CREATE SCHEMA test;
+			CREATE TABLE test.student
+			(
+				_id           VARCHAR(255) PRIMARY KEY,
+				date_of_birth VARCHAR(255),
+				is_graduated  BOOLEAN,
+				name          VARCHAR(255),
+				roll_no       FLOAT
+			);
+			INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
+			UPDATE test.student SET is_graduated = true WHERE _id = '635b79e231d82a8ab1de863b';
+			UPDATE test.student SET roll_no = NULL WHERE _id = '635b79e231d82a8ab1de863b';
+			DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
+		`
+
+	err := Read(inputFile, outputFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	
+	data, err := os.ReadFile(outputFile)
+	got := string(data)
+	
+	result, err := compareSqlStatement(t, exp, got)
+	if err != nil {
+		t.Fatalf("Error while comparing SQL statements: %v", err)
+	}
+
+	if !result {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+func TestOpenOutput(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "output.sql")
+	for _, tc := range []struct {
+		data string
+		appendOutput bool
+		exp string
+	}{
+		{data: "first\n", exp: "first\n"},
+		{data: "second\n", appendOutput: true, exp: "first\nsecond\n"},
+		{data: "third\n", exp: "third\n"},
+	} {
+		w, err := OpenOutput(outputFile, nil, tc.appendOutput)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := w.Write([]byte(tc.data)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		w.Close()
+
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("Error while reading output file: %v", err)
+		}
+		if string(data) != tc.exp {
+			t.Errorf("Expected %q but got %q", tc.exp, data)
+		}
+	}
+
+	// stdout is left open for its owner
+	var stdout bytes.Buffer
+	w, err := OpenOutput(Stdio, &stdout, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	w.Close()
+	if _, err := w.Write([]byte("after close\n")); err != nil || stdout.String() != "after close\n" {
+		t.Errorf("Expected stdout to be written after close but got %q, %v", stdout.String(), err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		exp string
+		expDeadLetters int
+	}{
+		{
+			name: "insert, update and delete",
+			input: `
+				{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}}
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "George Smith"}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}}
+				{"op": "d", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b"}}
+			`,
+			exp: `
+				CREATE SCHEMA test;
+				CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+				INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');
+				UPDATE test.student SET name = 'George Smith' WHERE _id = '635b79e231d82a8ab1de863b';
+				DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
+			`,
+		},
+		{
+			name: "table is created only once across oplogs",
+			input: `
+				{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}}
+				{"op": "i", "ns": "test.student", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith"}}
+			`,
+			exp: `
+				CREATE SCHEMA test;
+				CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+				INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');
+				INSERT INTO test.student (_id, name) VALUES ('14798c213f273a7ca2cf5174', 'George Smith');
+			`,
+		},
+		{
+			name: "failed oplogs go to dead letter",
+			input: `
+				{"op": "n", "ns": "", "o": {"msg": "periodic noop"}}
+				{"op": "d", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b"}}
+			`,
+			exp: "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';",
+			expDeadLetters: 1,
+		},
+		{
+			name: "malformed json goes to dead letter",
+			input: `
+				{"op": "d", "ns": 
+				{"op": "d", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b"}}
+			`,
+			exp: "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';",
+			expDeadLetters: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var output, deadLetters bytes.Buffer
+
+			err := Convert(strings.NewReader(tc.input), &output, WithDeadLetterWriter(&deadLetters))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := compareSqlStatement(t, tc.exp, output.String())
+			if err != nil {
+				t.Fatalf("Error while comparing SQL statements: %v", err)
+			}
+
+			if !result {
+				t.Errorf("Expected %q but got %q", tc.exp, output.String())
+			}
+
+			if got := strings.Count(deadLetters.String(), "\n"); got != tc.expDeadLetters {
+				t.Errorf("Expected %d dead letter entries but got %d: %q", tc.expDeadLetters, got, deadLetters.String())
+			}
+		})
+	}
+}
+
+func TestReadWithDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "oplog.json")
+	outputFile := filepath.Join(dir, "output.sql")
+	deadLetterFile := filepath.Join(dir, "dead_letter.jsonl")
+
+	input := `
+		{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}}
+		{"op": "n", "ns": "", "o": {"msg": "periodic noop"}}
+		{"op": "u", "ns": "student", "o": {"$v": 2, "diff": {"u": {"name": "George Smith"}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}}
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": 
+		{"op": "d", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b"}}
+	`
+	exp := `
+		CREATE SCHEMA test;
+		CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+		INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');
+		DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
+	`
+	if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
+		t.Fatalf("Error while writing input file: %v", err)
+	}
+
+	err := Read(inputFile, outputFile, WithDeadLetter(deadLetterFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Error while reading output file: %v", err)
+	}
+	got := string(data)
+
+	result, err := compareSqlStatement(t, exp, got)
+	if err != nil {
+		t.Fatalf("Error while comparing SQL statements: %v", err)
+	}
+
+	if !result {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+
+	// checking the dead letter entries
+	data, err = os.ReadFile(deadLetterFile)
+	if err != nil {
+		t.Fatalf("Error while reading dead letter file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 dead letter entries but got %d: %q", len(lines), data)
+	}
+
+	// malformed line is recorded as a string, as it is not json
+	expOplogs := []string{
+		`{"op":"n","ns":"","o":{"msg":"periodic noop"}}`,
+		`{"op":"u","ns":"student","o":{"$v":2,"diff":{"u":{"name":"George Smith"}}},"o2":{"_id":"635b79e231d82a8ab1de863b"}}`,
+		`"{\"op\": \"u\", \"ns\": \"test.student\", \"o\": {\"$v\": 2, \"diff\": {\"u\": {\"name\":"`,
+	}
+	for i, line := range lines {
+		var entry struct {
+			Oplog json.RawMessage `json:"oplog"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Error while decoding dead letter entry %q: %v", line, err)
+		}
+		if string(entry.Oplog) != expOplogs[i] {
+			t.Errorf("Expected dead letter entry for %s but got %s", expOplogs[i], entry.Oplog)
+		}
+		if entry.Error == "" {
+			t.Errorf("Expected error reason in dead letter entry %q", line)
+		}
+	}
+}
+
+func TestReadWithoutDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "oplog.json")
+	outputFile := filepath.Join(dir, "output.sql")
+
+	for _, input := range []string{
+		`{"op": "n", "ns": "", "o": {"msg": "periodic noop"}}`,
+		`{"op": "d", "ns": `,
+	} {
+		if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
+			t.Fatalf("Error while writing input file: %v", err)
+		}
+
+		err := Read(inputFile, outputFile)
+		if err == nil {
+			t.Errorf("Expected error for %q but got nil", input)
+		}
+	}
+}
+
+func TestReadWithCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "oplog.json")
+	outputFile := filepath.Join(dir, "output.sql")
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	input := `
+		{"ts": {"$timestamp": {"t": 1700000000, "i": 1}}, "t": 1, "h": 0, "op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}}
+		{"ts": {"$timestamp": {"t": 1700000000, "i": 2}}, "t": 1, "h": 0, "op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "George Smith"}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}}
+		{"ts": {"$timestamp": {"t": 1700000001, "i": 1}}, "t": 1, "h": 0, "op": "d", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b"}}
+	`
+	if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
+		t.Fatalf("Error while writing input file: %v", err)
+	}
+
+	// simulating a previous run which was interrupted after the first oplog
+	prevOutput := "CREATE SCHEMA test;CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');"
+	if err := os.WriteFile(outputFile, []byte(prevOutput), 0644); err != nil {
+		t.Fatalf("Error while writing output file: %v", err)
+	}
+	if err := os.WriteFile(checkpointFile, []byte(`{"ts": {"$timestamp": {"t": 1700000000, "i": 1}}}`), 0644); err != nil {
+		t.Fatalf("Error while writing checkpoint file: %v", err)
+	}
+
+	err := Read(inputFile, outputFile, WithCheckpoint(checkpointFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	exp := prevOutput + `
+		UPDATE test.student SET name = 'George Smith' WHERE _id = '635b79e231d82a8ab1de863b';
+		DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
+	`
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Error while reading output file: %v", err)
+	}
+	got := string(data)
+
+	result, err := compareSqlStatement(t, exp, got)
+	if err != nil {
+		t.Fatalf("Error while comparing SQL statements: %v", err)
+	}
+
+	if !result {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+
+	// checkpoint should point to the last oplog
+	ts, found, err := LoadCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !found || ts.T != 1700000001 || ts.I != 1 {
+		t.Errorf("Expected checkpoint at {1700000001 1} but got %v", ts)
+	}
+
+	// running again should not add anything to the output
+	err = Read(inputFile, outputFile, WithCheckpoint(checkpointFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err = os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Error while reading output file: %v", err)
+	}
+	if string(data) != got {
+		t.Errorf("Expected output to be unchanged on rerun but got %q", data)
+	}
+}
+
+func TestReadWithCheckpointKeepsTables(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "oplog.json")
+	outputFile := filepath.Join(dir, "output.sql")
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	input := `
+		{"ts": {"$timestamp": {"t": 1700000000, "i": 1}}, "op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}}
+		{"ts": {"$timestamp": {"t": 1700000000, "i": 2}}, "op": "i", "ns": "test.student", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith"}}
+		{"ts": {"$timestamp": {"t": 1700000000, "i": 3}}, "op": "i", "ns": "test.student", "o": {"_id": "3d9e2a4c8f1b7e6d5c4b3a29", "name": "Jane Doe", "roll_no": 51}}
+	`
+	if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
+		t.Fatalf("Error while writing input file: %v", err)
+	}
+
+	// simulating a previous run which was interrupted after the first oplog
+	prevOutput := "CREATE SCHEMA test;CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');"
+	if err := os.WriteFile(outputFile, []byte(prevOutput), 0644); err != nil {
+		t.Fatalf("Error while writing output file: %v", err)
+	}
+	if err := os.WriteFile(checkpointFile, []byte(`{"ts": {"$timestamp": {"t": 1700000000, "i": 1}}}`), 0644); err != nil {
+		t.Fatalf("Error while writing checkpoint file: %v", err)
+	}
+
+	if err := Read(inputFile, outputFile, WithCheckpoint(checkpointFile)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// the table created before the checkpoint is neither created nor altered again
+	exp := prevOutput +
+		"INSERT INTO test.student (_id, name) VALUES ('14798c213f273a7ca2cf5174', 'George Smith');" +
+		"ALTER TABLE test.student ADD roll_no  FLOAT;" +
+		"INSERT INTO test.student (_id, name, roll_no) VALUES ('3d9e2a4c8f1b7e6d5c4b3a29', 'Jane Doe', 51);"
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Error while reading output file: %v", err)
+	}
+	if string(data) != exp {
+		t.Errorf("Expected %q but got %q", exp, data)
+	}
+}
+
+func TestReadWithCheckpointResumeToken(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "events.json")
+	outputFile := filepath.Join(dir, "output.sql")
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	// events of a transaction share the same clusterTime
+	input := `
+		{"_id": {"_data": "01"}, "operationType": "insert", "clusterTime": {"$timestamp": {"t": 1700000000, "i": 1}}, "ns": {"db": "test", "coll": "student"}, "documentKey": {"_id": "635b79e231d82a8ab1de863b"}, "fullDocument": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}}
+		{"_id": {"_data": "02"}, "operationType": "insert", "clusterTime": {"$timestamp": {"t": 1700000000, "i": 1}}, "ns": {"db": "test", "coll": "student"}, "documentKey": {"_id": "14798c213f273a7ca2cf5174"}, "fullDocument": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith"}}
+		{"_id": {"_data": "03"}, "operationType": "insert", "clusterTime": {"$timestamp": {"t": 1700000000, "i": 1}}, "ns": {"db": "test", "coll": "student"}, "documentKey": {"_id": "3d9e2a4c8f1b7e6d5c4b3a29"}, "fullDocument": {"_id": "3d9e2a4c8f1b7e6d5c4b3a29", "name": "Jane Doe"}}
+	`
+	if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
+		t.Fatalf("Error while writing input file: %v", err)
+	}
+
+	// simulating a previous run which was interrupted after the first event
+	prevOutput := "CREATE SCHEMA test;CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');"
+	if err := os.WriteFile(outputFile, []byte(prevOutput), 0644); err != nil {
+		t.Fatalf("Error while writing output file: %v", err)
+	}
+	if err := os.WriteFile(checkpointFile, []byte(`{"ts": {"$timestamp": {"t": 1700000000, "i": 1}}, "resumeToken": {"_data": "01"}}`), 0644); err != nil {
+		t.Fatalf("Error while writing checkpoint file: %v", err)
+	}
+
+	if err := Read(inputFile, outputFile, WithCheckpoint(checkpointFile)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// events after the resume token are handled, even though they are at the ts of the checkpoint
+	exp := prevOutput +
+		"INSERT INTO test.student (_id, name) VALUES ('14798c213f273a7ca2cf5174', 'George Smith');" +
+		"INSERT INTO test.student (_id, name) VALUES ('3d9e2a4c8f1b7e6d5c4b3a29', 'Jane Doe');"
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Error while reading output file: %v", err)
+	}
+	if string(data) != exp {
+		t.Errorf("Expected %q but got %q", exp, data)
+	}
+
+	token, found, err := LoadResumeToken(checkpointFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exp := `{"_data":"03"}`; !found || string(token) != exp {
+		t.Errorf("Expected resume token %q but got %q", exp, token)
+	}
+}
+
+func TestReadIdempotent(t *testing.T) {
+	inputFile := "../testdata/oplog.json"
+	outputFile := filepath.Join(t.TempDir(), "output.sql")
+	exp := `
+			CREATE SCHEMA IF NOT EXISTS test;
+			CREATE TABLE IF NOT EXISTS test.student
+			(
+				_id           VARCHAR(255) PRIMARY KEY,
+				date_of_birth VARCHAR(255),
+				is_graduated  BOOLEAN,
+				name          VARCHAR(255),
+				roll_no       FLOAT
+			);
+			INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51)
+				ON CONFLICT (_id) DO UPDATE SET date_of_birth = EXCLUDED.date_of_birth, is_graduated = EXCLUDED.is_graduated, name = EXCLUDED.name, roll_no = EXCLUDED.roll_no;
+			UPDATE test.student SET is_graduated = true WHERE _id = '635b79e231d82a8ab1de863b';
+			UPDATE test.student SET roll_no = NULL WHERE _id = '635b79e231d82a8ab1de863b';
+			DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
+		`
+
+	err := Read(inputFile, outputFile, WithParserOptions(parser.WithIdempotent()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Error while reading output file: %v", err)
+	}
+	got := string(data)
+
+	result, err := compareSqlStatement(t, exp, got)
+	if err != nil {
+		t.Fatalf("Error while comparing SQL statements: %v", err)
+	}
+
+	if !result {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+func compareSqlStatement(t *testing.T, expected, got string) (bool, error) {
+	t.Helper()
+
+	expFp, err := pgquery.Fingerprint(expected)
+	if err != nil {
+		return false, err
+	}
+
+	gotFp, err := pgquery.Fingerprint(got)
+	if err != nil {
+		return false, err
+	}
+
+	return expFp == gotFp, nil
+}
+
+func TestReadFromWithInsertBatching(t *testing.T) {
+	input := `
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe"}}
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Jane Smith"}}}, "o2": {"_id": "3"}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "4", "name": "John Doe"}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "5", "name": "Mary Jane"}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "6", "name": "Peter Parker"}}
+	`
+	exp := []string{
+		"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+		"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Miller'), ('2', 'George Smith'), ('3', 'Jane Doe');",
+		"UPDATE test_student SET name = 'Jane Smith' WHERE _id = '3';",
+		"INSERT INTO test_student (_id, name) VALUES ('4', 'John Doe'), ('5', 'Mary Jane');",
+		"INSERT INTO test_student (_id, name) VALUES ('6', 'Peter Parker');",
+	}
+
+	sink := &collectSink{}
+	err := ReadFrom(strings.NewReader(input), sink, WithInsertBatching(3), WithParserOptions(parser.WithDialect(parser.SQLite)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(exp, sink.stmts) {
+		t.Errorf("Expected %q but got %q", exp, sink.stmts)
+	}
+}
+
+func TestReadFromWithCompaction(t *testing.T) {
+	input := `
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51}}
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 52}}}, "o2": {"_id": "1"}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith", "roll_no": 61}}
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 53}}}, "o2": {"_id": "1"}}
+		{"op": "d", "ns": "test.student", "o": {"_id": "2"}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe", "roll_no": 71}}
+	`
+	exp := []string{
+		"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), roll_no  FLOAT);",
+		"INSERT INTO test_student (_id, name, roll_no) VALUES ('1', 'Selena Miller', 53), ('3', 'Jane Doe', 71);",
+	}
+
+	sink := &collectSink{}
+	err := ReadFrom(strings.NewReader(input), sink, WithCompaction(), WithInsertBatching(10), WithParserOptions(parser.WithDialect(parser.SQLite)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(exp, sink.stmts) {
+		t.Errorf("Expected %q but got %q", exp, sink.stmts)
+	}
+}
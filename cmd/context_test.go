@@ -0,0 +1,101 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// yields the oplogs one by one, calling onNext with the index of each
+type sliceSource struct {
+	oplogs []string
+	index int
+	onNext func(index int)
+}
+
+func(s *sliceSource) Next() (json.RawMessage, error) {
+	if s.index >= len(s.oplogs) {
+		return nil, io.EOF
+	}
+	if s.onNext != nil {
+		s.onNext(s.index)
+	}
+	s.index++
+	return json.RawMessage(s.oplogs[s.index-1]), nil
+}
+
+func(s *sliceSource) Close() error {
+	return nil
+}
+
+func TestReadFromSourceContext(t *testing.T) {
+	var oplogs []string
+	for i := 0; i < 200; i++ {
+		oplogs = append(oplogs, fmt.Sprintf(`{"op": "i", "ns": "test.student", "ts": {"$timestamp": {"t": %d, "i": 1}}, "o": {"_id": "%d", "name": "Selena Miller"}}`, 1700000000+i, i))
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			src := &sliceSource{oplogs: oplogs, onNext: func(index int) {
+				if index == 50 {
+					cancel()
+				}
+			}}
+
+			var out bytes.Buffer
+			progress, err := ReadFromSourceContext(ctx, src, NewWriterSink(&out), WithBatchSize(1000), WithWorkers(workers))
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Expected context canceled error but got %v", err)
+			}
+
+			// statements of the oplogs handled before cancellation are handed over, despite the batch size
+			if progress.Index < 0 || progress.Index >= 50 {
+				t.Fatalf("Expected progress before the 50th oplog but got %d", progress.Index)
+			}
+			if got := strings.Count(out.String(), "INSERT INTO"); got != progress.Index+1 {
+				t.Errorf("Expected %d inserts as per progress but got %d", progress.Index+1, got)
+			}
+			expTs := primitive.Timestamp{T: uint32(1700000000 + progress.Index), I: 1}
+			if !progress.HasTs || !progress.Ts.Equal(expTs) {
+				t.Errorf("Expected progress ts %v but got %v", expTs, progress.Ts)
+			}
+			if src.index > 60 {
+				t.Errorf("Expected reading to stop promptly but read %d oplogs", src.index)
+			}
+		})
+	}
+}
+
+func TestConvertContext(t *testing.T) {
+	input := `{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}}
+		{"op": "d", "ns": "test.student", "o": {"_id": "1"}}`
+
+	var out bytes.Buffer
+	progress, err := ConvertContext(context.Background(), strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if progress.Index != 1 || progress.HasTs {
+		t.Errorf("Expected progress at index 1 without ts but got %+v", progress)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out.Reset()
+	progress, err = ConvertContext(ctx, strings.NewReader(input), &out)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context canceled error but got %v", err)
+	}
+	if progress.Index != -1 || out.Len() != 0 {
+		t.Errorf("Expected nothing to be converted but got %+v, %q", progress, out.String())
+	}
+}
@@ -0,0 +1,243 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// converter hands over the statements of the oplogs to the sink in batches,
+// recording the failed oplogs in the dead letter and saving the checkpoint
+type converter struct {
+	cfg *config
+	sink Sink
+	m *parser.MongoOplogParser
+	lastTs primitive.Timestamp		// ts of the checkpoint to resume from
+	lastToken json.RawMessage		// resume token of the checkpoint, if any
+	resume bool
+	pastToken bool					// oplogs after the resume token are being handled
+	deadLetter *deadLetterWriter
+
+	// statements of the oplogs which are yet to be handed over to the sink
+	// along with the ts of the last oplog among them
+	batch [][]parser.Statement
+	batchTs primitive.Timestamp
+	batchHasTs bool
+	batchToken json.RawMessage
+
+	read int						// number of oplogs read from the source
+	progress Progress				// oplogs whose statements are with the sink
+	batchProgress Progress			// oplogs whose statements are in the batch
+}
+
+// Progress tells how far a conversion got, counting only the oplogs whose statements
+// have been handed over to the sink, along with the ones skipped or dead lettered before them
+type Progress struct {
+	// Index of the last oplog in the input, starting from 0, -1 if there is none
+	Index int
+	// Ts of the last oplog which carries one, HasTs is false if there is none
+	Ts primitive.Timestamp
+	HasTs bool
+}
+
+// oplog read from the source, parsed but not yet resolved against the tables
+type preparedOplog struct {
+	index int
+	raw json.RawMessage
+	ts primitive.Timestamp
+	hasTs bool
+	prepared *parser.PreparedOplog
+}
+
+func newConverter(cfg *config, sink Sink) (*converter, error) {
+	c := &converter{
+		cfg: cfg,
+		sink: sink,
+		m: parser.NewMongoOplogParser(cfg.parserOpts...),
+		progress: Progress{Index: -1},
+		batchProgress: Progress{Index: -1},
+	}
+
+	// loading the checkpoint to resume from, if asked for
+	cp, resume, err := cfg.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	c.lastTs, c.lastToken, c.resume = cp.timestamp(), cp.ResumeToken, resume
+
+	// getting dead letter writer, if asked for
+	if cfg.deadLetterWriter != nil {
+		c.deadLetter = newDeadLetterWriter(nopCloser{cfg.deadLetterWriter})
+	} else if cfg.deadLetterFile != "" {
+		f, err := OpenOutput(cfg.deadLetterFile, os.Stdout, c.resume)
+		if err != nil {
+			return nil, fmt.Errorf("error while opening dead letter file: %v", err)
+		}
+		c.deadLetter = newDeadLetterWriter(f)
+	}
+	return c, nil
+}
+
+// converts the oplogs of the source one by one, until ctx is done
+func(c *converter) convert(ctx context.Context, src Source) error {
+	// handing over the pending statements while waiting for more oplogs
+	if n, ok := src.(idleNotifier); ok {
+		n.setOnIdle(c.flush)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		raw, err := src.Next()
+		if err != nil {
+			// sources which wait for more oplogs end once ctx is done as well
+			if err == io.EOF {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		if err := c.handle(c.prepare(ctx, c.next(), raw)); err != nil {
+			return err
+		}
+	}
+}
+
+// returns the index of the oplog read next
+func(c *converter) next() int {
+	c.read++
+	return c.read - 1
+}
+
+// parses the oplog, it is safe to be called concurrently
+func(c *converter) prepare(ctx context.Context, index int, raw json.RawMessage) *preparedOplog {
+	o := &preparedOplog{index: index, raw: raw}
+	o.prepared = c.m.PrepareContext(ctx, string(raw))
+	o.ts, o.hasTs = o.prepared.Timestamp()
+	return o
+}
+
+// adds the statements of the oplog to the batch, oplogs must be handled in order
+func(c *converter) handle(o *preparedOplog) error {
+	// oplog left midway on cancellation is not handled at all
+	stmts, err := c.m.ResolveStatements(o.prepared)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	// oplogs which were handled in the previous run are still parsed, so that the
+	// tables they created are known, but their statements are already in the output,
+	// and the failed ones are already in the dead letter
+	if c.isHandled(o) {
+		c.batchProgress.Index = o.index
+		return nil
+	}
+
+	c.batchProgress.Index = o.index
+	if o.hasTs {
+		c.batchTs, c.batchHasTs, c.batchToken = o.ts, true, o.prepared.ResumeToken()
+		c.batchProgress.Ts, c.batchProgress.HasTs = o.ts, true
+	}
+
+	if err != nil {
+		if c.deadLetter == nil {
+			return fmt.Errorf("error while getting equivalent sql: %v", err)
+		}
+
+		// moving on to the next oplog after recording the failure
+		if err := c.deadLetter.Write(o.raw, err); err != nil {
+			return err
+		}
+	} else if len(stmts) > 0 {
+		c.batch = append(c.batch, stmts)
+	}
+
+	if len(c.batch) >= c.cfg.batchSize {
+		return c.flush()
+	}
+	return nil
+}
+
+// tells if the oplog was handled in the previous run, i.e. it is not after the checkpoint
+// events of a transaction share the same ts, so the ones at the ts of the checkpoint
+// are told apart by the resume token, they are handled up to the one it belongs to
+func(c *converter) isHandled(o *preparedOplog) bool {
+	if !c.resume || !o.hasTs || o.ts.After(c.lastTs) {
+		return false
+	}
+
+	token := o.prepared.ResumeToken()
+	if !o.ts.Equal(c.lastTs) || c.lastToken == nil || token == nil {
+		return true
+	}
+	if c.pastToken {
+		return false
+	}
+	c.pastToken = bytes.Equal(token, c.lastToken)
+	return true
+}
+
+// hands over the pending statements to the sink
+func(c *converter) flush() error {
+	if len(c.batch) > 0 {
+		var err error
+		if ss, ok := c.sink.(StatementSink); ok {
+			err = ss.WriteStatements(c.getBatch())
+		} else {
+			err = c.sink.Write(c.getSQLBatch())
+		}
+		if err != nil {
+			return err
+		}
+		c.batch = nil
+	}
+	c.progress = c.batchProgress
+
+	// checkpoint is saved only once the statements are with the sink
+	if c.cfg.checkpointFile != "" && c.batchHasTs {
+		if err := saveCheckpoint(c.cfg.checkpointFile, c.batchTs, c.batchToken); err != nil {
+			return err
+		}
+		c.batchHasTs = false
+	}
+	return nil
+}
+
+// returns the pending statements grouped per oplog, or as a single group
+// once the statements across the oplogs are compacted or merged
+func(c *converter) getBatch() [][]parser.Statement {
+	if !c.cfg.compact && c.cfg.insertBatchRows <= 1 {
+		return c.batch
+	}
+
+	var stmts []parser.Statement
+	for _, oplogStmts := range c.batch {
+		stmts = append(stmts, oplogStmts...)
+	}
+	return [][]parser.Statement{c.m.CoalesceInserts(c.m.CompactStatements(stmts))}
+}
+
+// returns the sql of the pending statements, grouped as per getBatch
+func(c *converter) getSQLBatch() [][]string {
+	batch := make([][]string, 0, len(c.batch))
+	for _, stmts := range c.getBatch() {
+		batch = append(batch, parser.GetSQL(stmts))
+	}
+	return batch
+}
+
+func(c *converter) Close() error {
+	if c.deadLetter == nil {
+		return nil
+	}
+	return c.deadLetter.Close()
+}
@@ -0,0 +1,160 @@
+package reader
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DBSink executes the sql statements against a database through database/sql
+// Each batch is executed in a single transaction, so the statements of an oplog
+// are either applied together or not at all. Batch is retried as a whole on
+// transient errors like lost connection, deadlock or a locked database.
+type DBSink struct {
+	db *sql.DB
+	maxRetries int
+	retryDelay time.Duration
+	isTransient func(error) bool
+	sleep func(time.Duration)
+	ignoreDuplicateColumns bool
+}
+
+// DBSinkOption configures the behaviour of DBSink
+type DBSinkOption func(*DBSink)
+
+// WithRetry sets how many times a batch is retried on transient errors and
+// the delay before the first retry, which doubles on every retry after that.
+// Default is 3 retries starting with 100ms.
+func WithRetry(maxRetries int, retryDelay time.Duration) DBSinkOption {
+	return func(d *DBSink) {
+		d.maxRetries = maxRetries
+		d.retryDelay = retryDelay
+	}
+}
+
+// WithTransientErrorCheck replaces IsTransientError for deciding which errors are retried,
+// e.g. to look into the driver specific error codes
+func WithTransientErrorCheck(isTransient func(error) bool) DBSinkOption {
+	return func(d *DBSink) {
+		d.isTransient = isTransient
+	}
+}
+
+// WithIgnoreDuplicateColumns makes DBSink treat adding a column which already exists as done,
+// for replaying the output of parser.WithIdempotent against sqlite, which has no
+// ADD COLUMN IF NOT EXISTS. Other dialects get IF NOT EXISTS from the parser instead.
+func WithIgnoreDuplicateColumns() DBSinkOption {
+	return func(d *DBSink) {
+		d.ignoreDuplicateColumns = true
+	}
+}
+
+// NewDBSink returns a sink executing statements on the given database
+// db is owned by the caller and is not closed by the sink
+func NewDBSink(db *sql.DB, opts ...DBSinkOption) *DBSink {
+	d := &DBSink{
+		db: db,
+		maxRetries: 3,
+		retryDelay: 100 * time.Millisecond,
+		isTransient: IsTransientError,
+		sleep: time.Sleep,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+func(d *DBSink) Write(batch [][]string) error {
+	return d.withRetry(func() error {
+		return d.execBatch(batch)
+	})
+}
+
+func(d *DBSink) Close() error {
+	return nil
+}
+
+// executes all the statements of the batch in a single transaction
+func(d *DBSink) execBatch(batch [][]string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while beginning transaction: %w", err)
+	}
+
+	for _, stmts := range batch {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				if d.ignoreDuplicateColumns && isDuplicateColumnError(stmt, err) {
+					continue
+				}
+				tx.Rollback()
+				return fmt.Errorf("error while executing %q: %w", stmt, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// checks if the statement adds a column which already exists, sqlite leaves
+// the transaction as it is on such an error, so the batch can go on
+func isDuplicateColumnError(stmt string, err error) bool {
+	return strings.HasPrefix(stmt, "ALTER TABLE ") && strings.Contains(err.Error(), "duplicate column name")
+}
+
+func(d *DBSink) withRetry(fn func() error) error {
+	delay := d.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= d.maxRetries || !d.isTransient(err) {
+			return err
+		}
+
+		d.sleep(delay)
+		delay *= 2
+	}
+}
+
+// IsTransientError reports whether the error is worth retrying,
+// i.e. connection errors, serialization failures, deadlocks and busy databases
+func IsTransientError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// drivers like pgx expose the postgres error code through SQLState
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		state := stateErr.SQLState()
+		switch {
+		case strings.HasPrefix(state, "08"):	// connection exception
+			return true
+		case state == "40001", state == "40P01", state == "55P03", state == "57P01":
+			return true
+		}
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, transient := range []string{"database is locked", "database table is locked", "deadlock", "connection reset", "broken pipe"} {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package reader
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestReadToDBSink(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "oplog.json")
+
+	input := `
+		{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller", "roll_no": 51, "phone": {"work": "8130097989"}}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith", "roll_no": 21, "is_graduated": true}}
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 22}}}, "o2": {"_id": "14798c213f273a7ca2cf5174"}}
+		{"op": "d", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b"}}
+	`
+	if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
+		t.Fatalf("Error while writing input file: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Error while opening database: %v", err)
+	}
+	defer db.Close()
+
+	err = ReadToSink(inputFile, NewDBSink(db), WithBatchSize(3), WithParserOptions(parser.WithDialect(parser.SQLite)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rows, err := db.Query("SELECT _id, name, roll_no, is_graduated FROM test_student")
+	if err != nil {
+		t.Fatalf("Error while querying: %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var id, name string
+		var rollNo float64
+		var isGraduated bool
+		if err := rows.Scan(&id, &name, &rollNo, &isGraduated); err != nil {
+			t.Fatalf("Error while scanning: %v", err)
+		}
+		if id != "14798c213f273a7ca2cf5174" || name != "George Smith" || rollNo != 22 || !isGraduated {
+			t.Errorf("Unexpected row: %v %v %v %v", id, name, rollNo, isGraduated)
+		}
+		got = append(got, id)
+	}
+	if len(got) != 1 {
+		t.Errorf("Expected 1 row but got %d", len(got))
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM test_student_phone WHERE student__id = '635b79e231d82a8ab1de863b'").Scan(&count); err != nil {
+		t.Fatalf("Error while querying: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 phone row but got %d", count)
+	}
+}
+
+func TestReadToDBSinkQuotedValues(t *testing.T) {
+	input := `
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "O'Brien"}}
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "'); DROP TABLE test_student; --"}}}, "o2": {"_id": "1"}}
+	`
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Error while opening database: %v", err)
+	}
+	defer db.Close()
+
+	err = ReadFrom(strings.NewReader(input), NewDBSink(db), WithParserOptions(parser.WithDialect(parser.SQLite)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var name string
+	if err := db.QueryRow("SELECT name FROM test_student WHERE _id = '1'").Scan(&name); err != nil {
+		t.Fatalf("Error while querying: %v", err)
+	}
+	if exp := "'); DROP TABLE test_student; --"; name != exp {
+		t.Errorf("Expected %q but got %q", exp, name)
+	}
+}
+
+// columns set by an update before any insert has them are added to the table
+func TestReadToDBSinkColumnsAddedByUpdate(t *testing.T) {
+	input := `
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}}
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 51}, "i": {"email": "selena@example.com"}}}, "o2": {"_id": "1"}}
+	`
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Error while opening database: %v", err)
+	}
+	defer db.Close()
+
+	err = ReadFrom(strings.NewReader(input), NewDBSink(db), WithParserOptions(parser.WithDialect(parser.SQLite)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var rollNo float64
+	var email string
+	if err := db.QueryRow("SELECT roll_no, email FROM test_student WHERE _id = '1'").Scan(&rollNo, &email); err != nil {
+		t.Fatalf("Error while querying: %v", err)
+	}
+	if rollNo != 51 || email != "selena@example.com" {
+		t.Errorf("Expected 51, %q but got %v, %q", "selena@example.com", rollNo, email)
+	}
+}
+
+func TestDBSinkRollsBackFailedBatch(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Error while opening database: %v", err)
+	}
+	defer db.Close()
+
+	sink := NewDBSink(db)
+	err = sink.Write([][]string{{"CREATE TABLE test_student (_id VARCHAR(255) PRIMARY KEY);"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err = sink.Write([][]string{
+		{"INSERT INTO test_student (_id) VALUES ('635b79e231d82a8ab1de863b');"},
+		{"INSERT INTO test_student (_id) VALUES ('14798c213f273a7ca2cf5174');", "INSERT INTO test_student (_id) VALUES ('14798c213f273a7ca2cf5174');"},
+	})
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM test_student").Scan(&count); err != nil {
+		t.Fatalf("Error while querying: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no rows after rollback but got %d", count)
+	}
+}
+
+func TestDBSinkRetry(t *testing.T) {
+	transientErr := errors.New("database is locked")
+
+	tt := []struct {
+		name string
+		errs []error
+		expCalls int
+		expErr bool
+	}{
+		{name: "no error", errs: nil, expCalls: 1},
+		{name: "transient error then success", errs: []error{transientErr, transientErr}, expCalls: 3},
+		{name: "transient error beyond retries", errs: []error{transientErr, transientErr, transientErr, transientErr}, expCalls: 4, expErr: true},
+		{name: "permanent error", errs: []error{errors.New("syntax error")}, expCalls: 1, expErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var delays []time.Duration
+			sink := NewDBSink(nil, WithRetry(3, time.Millisecond))
+			sink.sleep = func(d time.Duration) {
+				delays = append(delays, d)
+			}
+
+			calls := 0
+			err := sink.withRetry(func() error {
+				calls++
+				if calls <= len(tc.errs) {
+					return tc.errs[calls-1]
+				}
+				return nil
+			})
+
+			if (err != nil) != tc.expErr {
+				t.Errorf("Expected error %v but got %v", tc.expErr, err)
+			}
+			if calls != tc.expCalls {
+				t.Errorf("Expected %d calls but got %d", tc.expCalls, calls)
+			}
+			for i := 1; i < len(delays); i++ {
+				if delays[i] != 2*delays[i-1] {
+					t.Errorf("Expected delay to double but got %v", delays)
+				}
+			}
+		})
+	}
+}
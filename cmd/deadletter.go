@@ -0,0 +1,51 @@
+package reader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// deadLetter is a single line of the dead letter file
+type deadLetter struct {
+	Oplog json.RawMessage `json:"oplog"`
+	Error string          `json:"error"`
+}
+
+// deadLetterWriter records the oplogs which couldn't be translated to sql
+// one JSON object per line, so that they can be triaged later
+type deadLetterWriter struct {
+	w       io.WriteCloser
+	encoder *json.Encoder
+}
+
+func newDeadLetterWriter(w io.WriteCloser) *deadLetterWriter {
+	return &deadLetterWriter{
+		w:       w,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// oplogs which are not valid json are recorded as json strings
+func(d *deadLetterWriter) Write(rawOplog json.RawMessage, reason error) error {
+	if !json.Valid(rawOplog) {
+		quoted, err := json.Marshal(string(rawOplog))
+		if err != nil {
+			return fmt.Errorf("error while writing to dead letter file: %v", err)
+		}
+		rawOplog = quoted
+	}
+
+	err := d.encoder.Encode(deadLetter{
+		Oplog: rawOplog,
+		Error: reason.Error(),
+	})
+	if err != nil {
+		return fmt.Errorf("error while writing to dead letter file: %v", err)
+	}
+	return nil
+}
+
+func(d *deadLetterWriter) Close() error {
+	return d.w.Close()
+}
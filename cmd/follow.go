@@ -0,0 +1,120 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// followReader reads a file which keeps growing, like tail -F
+// Instead of returning io.EOF at the end of the file, it waits for more data to be appended.
+// If the file is truncated, it starts over from the beginning, and if the file is rotated,
+// i.e. replaced by a new file at the same path, it moves on to the new file.
+// io.EOF is returned only once stop or done is closed.
+type followReader struct {
+	path string
+	f *os.File
+	offset int64
+	pollInterval time.Duration
+	stop <-chan struct{}
+	done <-chan struct{}
+	onIdle func() error		// called before waiting for more data
+}
+
+func newFollowReader(path string, pollInterval time.Duration, stop, done <-chan struct{}) (*followReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &followReader{
+		path: path,
+		f: f,
+		pollInterval: pollInterval,
+		stop: stop,
+		done: done,
+	}, nil
+}
+
+func(fr *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := fr.f.Read(p)
+		fr.offset += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		// reached the end of the file, checking if it was truncated or rotated
+		reopened, err := fr.checkFile()
+		if err != nil {
+			return 0, err
+		}
+		if reopened {
+			continue
+		}
+
+		if fr.onIdle != nil {
+			if err := fr.onIdle(); err != nil {
+				return 0, err
+			}
+		}
+
+		select {
+		case <-fr.stop:
+			return 0, io.EOF
+		case <-fr.done:
+			return 0, io.EOF
+		case <-time.After(fr.pollInterval):
+		}
+	}
+}
+
+// starts over if the file was truncated, or moves to the new file if it was rotated
+// reopened is true if there might be more data to read right away
+func(fr *followReader) checkFile() (reopened bool, err error) {
+	pathInfo, err := os.Stat(fr.path)
+	if err != nil {
+		// new file may not have been created yet after rotation
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	fileInfo, err := fr.f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if !os.SameFile(fileInfo, pathInfo) {
+		f, err := os.Open(fr.path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return false, nil
+			}
+			return false, err
+		}
+		fr.f.Close()
+		fr.f = f
+		fr.offset = 0
+		return true, nil
+	}
+
+	if fileInfo.Size() < fr.offset {
+		if _, err := fr.f.Seek(0, io.SeekStart); err != nil {
+			return false, fmt.Errorf("error while seeking truncated file: %v", err)
+		}
+		fr.offset = 0
+		return true, nil
+	}
+	return false, nil
+}
+
+func(fr *followReader) Close() error {
+	return fr.f.Close()
+}
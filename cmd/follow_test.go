@@ -0,0 +1,112 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collects the statements handed over by the reader
+type collectSink struct {
+	mu sync.Mutex
+	stmts []string
+}
+
+func(c *collectSink) Write(batch [][]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, stmts := range batch {
+		c.stmts = append(c.stmts, stmts...)
+	}
+	return nil
+}
+
+func(c *collectSink) Close() error {
+	return nil
+}
+
+func(c *collectSink) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strings.Join(c.stmts, "\n")
+}
+
+// waits till the sink has received a statement containing exp
+func waitForStatement(t *testing.T, sink *collectSink, exp string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if strings.Contains(sink.String(), exp) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %q, got %q", exp, sink.String())
+}
+
+func appendToFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Error while opening file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("Error while writing file: %v", err)
+	}
+}
+
+func TestReadToSinkWithFollow(t *testing.T) {
+	inputFile := filepath.Join(t.TempDir(), "oplog.json")
+	appendToFile(t, inputFile, `{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}}`+"\n")
+
+	sink := &collectSink{}
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- ReadToSink(inputFile, sink, WithFollow(10*time.Millisecond, stop), WithBatchSize(100))
+	}()
+
+	// pending statements are handed over while waiting, irrespective of batch size
+	waitForStatement(t, sink, "VALUES ('1', 'Selena Miller')")
+
+	// appended oplogs are picked up, along with the oplogs written partially
+	appendToFile(t, inputFile, `{"op": "i", "ns": "test.student", "o": {"_id": "2", `)
+	time.Sleep(30 * time.Millisecond)
+	appendToFile(t, inputFile, `"name": "George Smith", "roll_no": 21}}`+"\n")
+	waitForStatement(t, sink, "VALUES ('2', 'George Smith', 21)")
+
+	// truncated file is read from the start, without creating the table again
+	if err := os.WriteFile(inputFile, []byte(`{"op": "i", "ns": "test.student", "o": {"_id": "3"}}`+"\n"), 0644); err != nil {
+		t.Fatalf("Error while truncating file: %v", err)
+	}
+	waitForStatement(t, sink, "VALUES ('3')")
+
+	// rotated file is followed to the new file
+	if err := os.Rename(inputFile, inputFile+".1"); err != nil {
+		t.Fatalf("Error while rotating file: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	appendToFile(t, inputFile, `{"op": "d", "ns": "test.student", "o": {"_id": "1"}}`+"\n")
+	waitForStatement(t, sink, "DELETE FROM test.student WHERE _id = '1';")
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for read to stop")
+	}
+
+	if got := strings.Count(sink.String(), "CREATE TABLE"); got != 1 {
+		t.Errorf("Expected table to be created once but got %d times: %q", got, sink.String())
+	}
+}
@@ -0,0 +1,163 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoCursor is implemented by both mongo.Cursor and mongo.ChangeStream
+type mongoCursor interface {
+	Next(ctx context.Context) bool
+	TryNext(ctx context.Context) bool
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// MongoSource is a Source which tails a live MongoDB deployment, either through
+// the oplog of a replica set member or through a change stream. It waits for new
+// entries instead of ending, and returns io.EOF only once its context is done.
+// Entries are yielded as canonical extended json, which the parser understands, so that
+// the int64 values above 2^53 keep their precision.
+type MongoSource struct {
+	ctx context.Context
+	cursor mongoCursor
+	current func() bson.Raw
+	onIdle func() error		// called before waiting for more entries
+	startsAfter bool		// started after the given ts or resume token
+}
+
+// NewOplogSource opens a tailable cursor on local.oplog.rs of the replica set which
+// client is connected to, yielding the insert, update and delete entries after from, along
+// with the applyOps entries holding the writes of the multi-document transactions.
+// Other entries, like no-ops, commands and chunk migrations, are left out.
+// Nothing it yields is skipped by WithCheckpoint if from is not zero.
+func NewOplogSource(ctx context.Context, client *mongo.Client, from primitive.Timestamp) (*MongoSource, error) {
+	filter, opts := getOplogQuery(from)
+	cursor, err := client.Database("local").Collection("oplog.rs").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening oplog cursor: %v", err)
+	}
+	src := newMongoSource(ctx, cursor)
+	src.startsAfter = !from.IsZero()
+	return src, nil
+}
+
+// NewChangeStreamSource opens a change stream on the deployment which client is connected
+// to, yielding the insert, update, replace and delete events. If resumeToken is given, e.g.
+// from LoadResumeToken, the stream starts after the event it belongs to, else if from is
+// not zero, it starts at that cluster time, otherwise with the events from now on.
+// Nothing it yields is skipped by WithCheckpoint if it starts after resumeToken.
+func NewChangeStreamSource(ctx context.Context, client *mongo.Client, from primitive.Timestamp, resumeToken json.RawMessage) (*MongoSource, error) {
+	pipeline, opts, err := getChangeStreamQuery(from, resumeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := client.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening change stream: %v", err)
+	}
+	return &MongoSource{
+		ctx: ctx,
+		cursor: stream,
+		current: func() bson.Raw { return stream.Current },
+		startsAfter: resumeToken != nil,
+	}, nil
+}
+
+// returns the filter and options for the cursor on the oplog entries after from
+func getOplogQuery(from primitive.Timestamp) (bson.D, *options.FindOptions) {
+	filter := bson.D{
+		{Key: "ts", Value: bson.D{{Key: "$gt", Value: from}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "op", Value: bson.D{{Key: "$in", Value: bson.A{"i", "u", "d"}}}}},
+			bson.D{{Key: "op", Value: "c"}, {Key: "o.applyOps", Value: bson.D{{Key: "$exists", Value: true}}}},
+		}},
+		{Key: "fromMigrate", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	return filter, options.Find().SetCursorType(options.TailableAwait)
+}
+
+// returns the pipeline and options for the change stream, starting after resumeToken if
+// given, else at from if it is not zero
+func getChangeStreamQuery(from primitive.Timestamp, resumeToken json.RawMessage) (mongo.Pipeline, *options.ChangeStreamOptions, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}}}}}}},
+	}
+	opts := options.ChangeStream()
+	if resumeToken != nil {
+		var token bson.Raw
+		if err := bson.UnmarshalExtJSON(resumeToken, false, &token); err != nil {
+			return nil, nil, fmt.Errorf("error while decoding resume token: %v", err)
+		}
+		opts.SetStartAfter(token)
+	} else if !from.IsZero() {
+		opts.SetStartAtOperationTime(&from)
+	}
+	return pipeline, opts, nil
+}
+
+func newMongoSource(ctx context.Context, cursor *mongo.Cursor) *MongoSource {
+	return &MongoSource{
+		ctx: ctx,
+		cursor: cursor,
+		current: func() bson.Raw { return cursor.Current },
+	}
+}
+
+func(s *MongoSource) Next() (json.RawMessage, error) {
+	if !s.cursor.TryNext(s.ctx) {
+		if err := s.err(); err != nil {
+			return nil, err
+		}
+
+		// handing over the pending statements before waiting for more entries
+		if s.onIdle != nil {
+			if err := s.onIdle(); err != nil {
+				return nil, err
+			}
+		}
+
+		if !s.cursor.Next(s.ctx) {
+			if err := s.err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+	}
+
+	data, err := bson.MarshalExtJSON(s.current(), true, false)
+	if err != nil {
+		return nil, fmt.Errorf("error while encoding oplog to json: %v", err)
+	}
+	return data, nil
+}
+
+// returns the error of the cursor, cancellation of the context is not one
+func(s *MongoSource) err() error {
+	err := s.cursor.Err()
+	if err == nil || s.ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("error while reading oplog: %v", err)
+}
+
+func(s *MongoSource) setOnIdle(onIdle func() error) {
+	s.onIdle = onIdle
+}
+
+func(s *MongoSource) resumed() bool {
+	return s.startsAfter
+}
+
+// Close closes the cursor, the client is left to its owner
+func(s *MongoSource) Close() error {
+	return s.cursor.Close(context.Background())
+}
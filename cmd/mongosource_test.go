@@ -0,0 +1,243 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// replays the recorded oplog entries, then waits for more like a tailable cursor
+type recordedCursor struct {
+	*mongo.Cursor
+}
+
+func(c recordedCursor) Next(ctx context.Context) bool {
+	if c.Cursor.Next(ctx) {
+		return true
+	}
+	<-ctx.Done()
+	return false
+}
+
+// builds a source over the entries recorded in the extended json file
+func newRecordedSource(t *testing.T, ctx context.Context, path string) *MongoSource {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error while opening fixture: %v", err)
+	}
+	defer f.Close()
+
+	var docs []interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var doc bson.Raw
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+			t.Fatalf("Error while decoding fixture: %v", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	cursor, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+	if err != nil {
+		t.Fatalf("Error while creating cursor: %v", err)
+	}
+	return &MongoSource{
+		ctx: ctx,
+		cursor: recordedCursor{cursor},
+		current: func() bson.Raw { return cursor.Current },
+	}
+}
+
+func TestReadFromMongoSource(t *testing.T) {
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := newRecordedSource(t, ctx, "../testdata/oplog_rs.json")
+	sink := &collectSink{}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ReadFromSource(src, sink, WithBatchSize(10), WithCheckpoint(checkpointFile))
+	}()
+
+	// pending statements are handed over while waiting for more entries
+	waitForStatement(t, sink, "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';")
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	exp := `CREATE SCHEMA test;
+CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, date_of_birth  VARCHAR(255), is_graduated  BOOLEAN, name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-03T00:00:00.000Z', false, 'Selena Miller', 51);
+UPDATE test.student SET is_graduated = true WHERE _id = '635b79e231d82a8ab1de863b';
+DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';`
+	if sink.String() != exp {
+		t.Errorf("Expected %q but got %q", exp, sink.String())
+	}
+
+	ts, found, err := LoadCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expTs := primitive.Timestamp{T: 1698134247, I: 2}
+	if !found || !ts.Equal(expTs) {
+		t.Errorf("Expected checkpoint %v but got %v", expTs, ts)
+	}
+}
+
+func TestReadFromResumedMongoSource(t *testing.T) {
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := os.WriteFile(checkpointFile, []byte(`{"ts": {"$timestamp": {"t": 1698134247, "i": 2}}}`), 0644); err != nil {
+		t.Fatalf("Error while writing checkpoint file: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// source which starts after the checkpoint on its own, nothing it yields is skipped
+	src := newRecordedSource(t, ctx, "../testdata/oplog_rs.json")
+	src.startsAfter = true
+	sink := &collectSink{}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ReadFromSource(src, sink, WithCheckpoint(checkpointFile))
+	}()
+
+	waitForStatement(t, sink, "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';")
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestReadFromMongoSourceLargeIntegers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oplog_rs.json")
+	entry := `{"ts": {"$timestamp": {"t": 1698134245, "i": 1}}, "op": "i", "ns": "test.student", "o": {"_id": "1", "views": {"$numberLong": "9007199254740993"}}}`
+	if err := os.WriteFile(path, []byte(entry + "\n"), 0644); err != nil {
+		t.Fatalf("Error while writing fixture: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := newRecordedSource(t, ctx, path)
+	sink := &collectSink{}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ReadFromSource(src, sink)
+	}()
+
+	// int64 above 2^53 would be rounded if it went through float64
+	waitForStatement(t, sink, "VALUES ('1', 9007199254740993);")
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestGetOplogQuery(t *testing.T) {
+	from := primitive.Timestamp{T: 1698134247, I: 2}
+	filter, opts := getOplogQuery(from)
+
+	exp := bson.D{
+		{Key: "ts", Value: bson.D{{Key: "$gt", Value: from}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "op", Value: bson.D{{Key: "$in", Value: bson.A{"i", "u", "d"}}}}},
+			bson.D{{Key: "op", Value: "c"}, {Key: "o.applyOps", Value: bson.D{{Key: "$exists", Value: true}}}},
+		}},
+		{Key: "fromMigrate", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	if !reflect.DeepEqual(filter, exp) {
+		t.Errorf("Expected filter %v but got %v", exp, filter)
+	}
+	if opts.CursorType == nil || *opts.CursorType != options.TailableAwait {
+		t.Errorf("Expected a tailable await cursor but got %v", opts.CursorType)
+	}
+}
+
+func TestGetChangeStreamQuery(t *testing.T) {
+	from := primitive.Timestamp{T: 1698134247, I: 2}
+	expPipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}}}}}}},
+	}
+
+	tt := []struct {
+		name string
+		from primitive.Timestamp
+		resumeToken json.RawMessage
+		expStartAt *primitive.Timestamp
+		expStartAfter interface{}
+		expErr bool
+	}{
+		{name: "from now on"},
+		{name: "from the cluster time", from: from, expStartAt: &from},
+		{name: "after the resume token", from: from, resumeToken: json.RawMessage(`{"_data":"8265"}`), expStartAfter: getBSONDoc(t, `{"_data":"8265"}`)},
+		{name: "invalid resume token", resumeToken: json.RawMessage(`"8265"`), expErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			pipeline, opts, err := getChangeStreamQuery(tc.from, tc.resumeToken)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("Expected error %v but got %v", tc.expErr, err)
+			}
+			if tc.expErr {
+				return
+			}
+
+			if !reflect.DeepEqual(pipeline, expPipeline) {
+				t.Errorf("Expected pipeline %v but got %v", expPipeline, pipeline)
+			}
+			if !reflect.DeepEqual(opts.StartAtOperationTime, tc.expStartAt) {
+				t.Errorf("Expected start at %v but got %v", tc.expStartAt, opts.StartAtOperationTime)
+			}
+			if !reflect.DeepEqual(opts.StartAfter, tc.expStartAfter) {
+				t.Errorf("Expected start after %v but got %v", tc.expStartAfter, opts.StartAfter)
+			}
+		})
+	}
+}
+
+// the sources fail to open once no server can be selected
+func TestNewMongoSourceError(t *testing.T) {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer client.Disconnect(ctx)
+
+	if _, err := NewOplogSource(ctx, client, primitive.Timestamp{}); err == nil || !strings.HasPrefix(err.Error(), "error while opening oplog cursor") {
+		t.Errorf("Expected error while opening oplog cursor but got %v", err)
+	}
+	if _, err := NewChangeStreamSource(ctx, client, primitive.Timestamp{}, nil); err == nil || !strings.HasPrefix(err.Error(), "error while opening change stream") {
+		t.Errorf("Expected error while opening change stream but got %v", err)
+	}
+	if _, err := NewChangeStreamSource(ctx, client, primitive.Timestamp{}, json.RawMessage(`[`)); err == nil || !strings.HasPrefix(err.Error(), "error while decoding resume token") {
+		t.Errorf("Expected error while decoding resume token but got %v", err)
+	}
+}
+
+// returns the bson document for the extended json
+func getBSONDoc(t *testing.T, extJSON string) bson.Raw {
+	t.Helper()
+
+	var doc bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(extJSON), false, &doc); err != nil {
+		t.Fatalf("Error while decoding %s: %v", extJSON, err)
+	}
+	return doc
+}
@@ -0,0 +1,655 @@
+// oplog2sql converts MongoDB oplogs to equivalent SQL statements.
+//
+// Usage:
+//
+//	oplog2sql convert  [flags]	writes the sql statements for the oplogs
+//	oplog2sql validate [flags]	reports the oplogs which can't be converted
+//	oplog2sql schema   [flags]	writes the create statements for the tables implied by the oplogs
+//	oplog2sql snapshot [flags]	writes the final rows of each table to a csv or parquet file
+//
+// Input and output default to stdin and stdout, "-" can be used to refer to them explicitly.
+// convert can tail a live MongoDB deployment instead of reading the input, with -mongo-uri,
+// and execute the statements against a database instead of writing them, with -db.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	reader "github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/cmd"
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	// database/sql driver for -db, others can be registered the same way
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// exit codes
+const (
+	exitOK      = 0 // all the oplogs were handled
+	exitFailure = 1 // conversion failed or invalid oplogs were found
+	exitUsage   = 2 // wrong command or flags
+)
+
+const usage = `usage: oplog2sql <command> [flags]
+
+commands:
+  convert    writes the sql statements for the oplogs
+  validate   reports the oplogs which can't be converted
+  schema     writes the create statements for the tables implied by the oplogs
+  snapshot   writes the final rows of each table to a csv or parquet file
+
+run "oplog2sql <command> -h" to see the flags of a command
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "convert":
+		return runConvert(args[1:], stdin, stdout, stderr)
+	case "validate":
+		return runValidate(args[1:], stdin, stdout, stderr)
+	case "schema":
+		return runSchema(args[1:], stdin, stdout, stderr)
+	case "snapshot":
+		return runSnapshot(args[1:], stdin, stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "oplog2sql: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
+
+// flags shared by all the commands
+type commonFlags struct {
+	input      string
+	output     string
+	dialect    string
+	idempotent bool
+	namespaces listFlag
+	mappings   listFlag
+	dbPrefix   string
+	lowerCase  bool
+	drops      listFlag
+	renames    listFlag
+	masks      listFlag
+}
+
+// listFlag collects the values of a flag which can be repeated or comma separated
+// commas within a /regex/ namespace, i.e. /^test\.a{1,2}$/, don't separate the values
+type listFlag []string
+
+func(l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func(l *listFlag) Set(value string) error {
+	for _, v := range splitList(value) {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// splits the value on the commas outside of the /regex/ a value may start with, after the !
+func splitList(value string) []string {
+	var values []string
+	start, inRegex, escaped := 0, false, false
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case escaped:
+			escaped = false
+		case inRegex && c == '\\':
+			escaped = true
+		case inRegex && c == '/':
+			inRegex = false
+		case c == '/' && strings.TrimLeft(strings.TrimSpace(value[start:i]), "!") == "":
+			inRegex = true
+		case c == ',' && !inRegex:
+			values = append(values, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(values, value[start:])
+}
+
+func newFlagSet(name string, stderr io.Writer, c *commonFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("oplog2sql "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&c.input, "input", "-", "oplog file to read, - for stdin")
+	fs.StringVar(&c.output, "output", "-", "file to write to, - for stdout")
+	fs.StringVar(&c.dialect, "dialect", "postgres", "sql dialect, postgres or sqlite")
+	fs.BoolVar(&c.idempotent, "idempotent", false, "generate statements which are safe to replay")
+	fs.Var(&c.namespaces, "ns", "namespaces to convert, as exact names, globs like test.* or /regex/, prefixed with ! to exclude (repeatable)")
+	fs.Var(&c.mappings, "map", "rename a namespace or database, i.e. test.student=school.students or test=school (repeatable)")
+	fs.StringVar(&c.dbPrefix, "db-prefix", "", "prefix for the schema names, applied after -map")
+	fs.BoolVar(&c.lowerCase, "lowercase", false, "make the schema and table names lowercase")
+	fs.Var(&c.drops, "drop", "leave out a field, i.e. test.student:password (repeatable)")
+	fs.Var(&c.renames, "rename", "rename a field, i.e. test.student:date_of_birth=dob (repeatable)")
+	fs.Var(&c.masks, "mask", "replace a field with its SHA-256 hash, i.e. test.*:email (repeatable)")
+	return fs
+}
+
+// parses the flags, returning the exit code if the command should not proceed
+func parseFlags(fs *flag.FlagSet, args []string, stderr io.Writer) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "%s: unexpected arguments %q\n", fs.Name(), fs.Args())
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+func(c *commonFlags) parserOptions() ([]parser.Option, error) {
+	dialect, err := parser.ParseDialect(c.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []parser.Option{parser.WithDialect(dialect)}
+	if c.idempotent {
+		opts = append(opts, parser.WithIdempotent())
+	}
+	if len(c.namespaces) > 0 {
+		filter, err := parser.NewNamespaceFilter(c.namespaces...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parser.WithNamespaceFilter(filter))
+	}
+	if len(c.mappings) > 0 || c.dbPrefix != "" || c.lowerCase {
+		mapper, err := parser.NewNamespaceMapper(c.mappings...)
+		if err != nil {
+			return nil, err
+		}
+		mapper.DBPrefix = c.dbPrefix
+		mapper.LowerCase = c.lowerCase
+		opts = append(opts, parser.WithNamespaceMapper(mapper))
+	}
+	if len(c.drops) > 0 || len(c.renames) > 0 || len(c.masks) > 0 {
+		rules, err := c.fieldRules()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parser.WithFieldRules(rules))
+	}
+	return opts, nil
+}
+
+// builds the field rules from the flags of the form <ns>:<field>[=<new name>]
+func(c *commonFlags) fieldRules() (*parser.FieldRules, error) {
+	rules := parser.NewFieldRules()
+	add := func(specs []string, withNewName bool, addRule func(ns, field, newName string) error) error {
+		for _, spec := range specs {
+			ns, field, ok := strings.Cut(spec, ":")
+			var newName string
+			if ok && withNewName {
+				field, newName, ok = strings.Cut(field, "=")
+			}
+			if !ok {
+				return fmt.Errorf("invalid field rule %q", spec)
+			}
+			if err := addRule(ns, field, newName); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := add(c.drops, false, func(ns, field, _ string) error { return rules.Drop(ns, field) }); err != nil {
+		return nil, err
+	}
+	if err := add(c.renames, true, rules.Rename); err != nil {
+		return nil, err
+	}
+	if err := add(c.masks, false, func(ns, field, _ string) error { return rules.Mask(ns, field) }); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var c commonFlags
+	var deadLetterFile, checkpointFile string
+	var follow, changeStream, compact, cdcSchema bool
+	var pollInterval time.Duration
+	var mongoURI, format, dbSpec string
+	var workers, insertBatch, batchSize int
+	fs := newFlagSet("convert", stderr, &c)
+	fs.StringVar(&format, "format", "sql", "output format, sql, copy for COPY blocks in place of the inserts, csv for a directory of csv files and a manifest, or cdc for Debezium style change events")
+	fs.BoolVar(&cdcSchema, "cdc-schema", false, "wrap the change events along with their schema with -format cdc")
+	fs.StringVar(&deadLetterFile, "dead-letter", "", "JSONL file to record the oplogs which fail, instead of stopping at them")
+	fs.StringVar(&checkpointFile, "checkpoint", "", "file to save the ts of the last handled oplog to, and resume from")
+	fs.BoolVar(&follow, "follow", false, "keep reading the oplogs appended to the input file until interrupted")
+	fs.DurationVar(&pollInterval, "poll-interval", time.Second, "how often to check for appended oplogs with -follow")
+	fs.StringVar(&mongoURI, "mongo-uri", "", "tail the oplog of this MongoDB replica set until interrupted, instead of reading the input")
+	fs.BoolVar(&changeStream, "change-stream", false, "tail a change stream instead of the oplog with -mongo-uri")
+	fs.IntVar(&workers, "workers", 1, "number of goroutines parsing the oplogs, the output stays in order")
+	fs.IntVar(&insertBatch, "insert-batch", 1, "merge up to this many consecutive inserts into the same table into a multi-row insert")
+	fs.BoolVar(&compact, "compact", false, "fold the statements for the same row within a batch into their net effect, i.e. insert and delete into nothing")
+	fs.IntVar(&batchSize, "batch-size", 1, "number of oplogs whose statements are written at once, COPY blocks don't span the batches")
+	fs.StringVar(&dbSpec, "db", "", "execute the statements against this database instead of writing them, as <driver>:<dsn>, i.e. sqlite3:replica.db")
+	if code, ok := parseFlags(fs, args, stderr); !ok {
+		return code
+	}
+	if err := checkFormat(format, &c, checkpointFile); err != nil {
+		fmt.Fprintf(stderr, "oplog2sql convert: %v\n", err)
+		return exitUsage
+	}
+	if err := checkDB(dbSpec, format, &c); err != nil {
+		fmt.Fprintf(stderr, "oplog2sql convert: %v\n", err)
+		return exitUsage
+	}
+
+	parserOpts, err := c.parserOptions()
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql convert: %v\n", err)
+		return exitUsage
+	}
+
+	opts := []reader.Option{reader.WithParserOptions(parserOpts...), reader.WithWorkers(workers), reader.WithBatchSize(batchSize)}
+	if insertBatch > 1 {
+		opts = append(opts, reader.WithInsertBatching(insertBatch))
+	}
+	if compact {
+		opts = append(opts, reader.WithCompaction())
+	}
+	if deadLetterFile != "" {
+		opts = append(opts, reader.WithDeadLetter(deadLetterFile))
+	}
+	if checkpointFile != "" {
+		opts = append(opts, reader.WithCheckpoint(checkpointFile))
+	}
+
+	// conversion stops on interrupt, after handing over the pending statements
+	// interrupt is how following and tailing are meant to end, so only then it is not a failure
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if follow {
+		opts = append(opts, reader.WithFollow(pollInterval, ctx.Done()))
+	}
+
+	// output of the previous run is kept while resuming from a checkpoint
+	appendOutput := false
+	if checkpointFile != "" {
+		if _, err := os.Stat(checkpointFile); err == nil {
+			appendOutput = true
+		}
+	}
+	var sink reader.Sink
+	var closeOutput func() error
+	if dbSpec != "" {
+		sink, closeOutput, err = openDBSink(ctx, dbSpec, &c)
+	} else {
+		sink, closeOutput, err = openSink(format, c.output, stdout, appendOutput, cdcSchema)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql convert: %v\n", err)
+		return exitFailure
+	}
+
+	// input file is opened by the reader, so that it can be followed
+	var progress reader.Progress
+	if mongoURI != "" {
+		progress, err = convertFromMongo(ctx, mongoURI, changeStream, checkpointFile, sink, opts)
+	} else if c.input == reader.Stdio {
+		progress, err = reader.ReadFromContext(ctx, stdin, sink, opts...)
+	} else {
+		progress, err = reader.ReadToSinkContext(ctx, c.input, sink, opts...)
+	}
+	if closeErr := closeOutput(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(stderr, "oplog2sql convert: interrupted, %s\n", describeProgress(progress))
+		if follow || mongoURI != "" {
+			return exitOK
+		}
+		return exitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql convert: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// tells up to which oplog the statements have been written
+func describeProgress(p reader.Progress) string {
+	if p.Index < 0 {
+		return "no oplogs converted"
+	}
+	if p.HasTs {
+		return fmt.Sprintf("converted up to oplog %d (ts %d.%d)", p.Index, p.Ts.T, p.Ts.I)
+	}
+	return fmt.Sprintf("converted up to oplog %d", p.Index)
+}
+
+// tails the oplog or a change stream of the deployment at uri until ctx is done
+// tailing starts after the checkpoint, if there is one, change streams are
+// resumed after its resume token, if it has one
+func convertFromMongo(ctx context.Context, uri string, changeStream bool, checkpointFile string, sink reader.Sink, opts []reader.Option) (reader.Progress, error) {
+	none := reader.Progress{Index: -1}
+	var from primitive.Timestamp
+	var resumeToken json.RawMessage
+	if checkpointFile != "" {
+		ts, found, err := reader.LoadCheckpoint(checkpointFile)
+		if err != nil {
+			sink.Close()
+			return none, err
+		}
+		if found {
+			from = ts
+		}
+		if resumeToken, _, err = reader.LoadResumeToken(checkpointFile); err != nil {
+			sink.Close()
+			return none, err
+		}
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		sink.Close()
+		return none, fmt.Errorf("error while connecting to mongodb: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	var src *reader.MongoSource
+	if changeStream {
+		src, err = reader.NewChangeStreamSource(ctx, client, from, resumeToken)
+	} else {
+		src, err = reader.NewOplogSource(ctx, client, from)
+	}
+	if err != nil {
+		sink.Close()
+		return none, err
+	}
+	return reader.ReadFromSourceContext(ctx, src, sink, opts...)
+}
+
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var c commonFlags
+	fs := newFlagSet("validate", stderr, &c)
+	if code, ok := parseFlags(fs, args, stderr); !ok {
+		return code
+	}
+
+	m, code := newParser(&c, "validate", stderr)
+	if m == nil {
+		return code
+	}
+
+	input, err := reader.OpenInput(c.input, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql validate: error while opening file: %v\n", err)
+		return exitFailure
+	}
+	defer input.Close()
+
+	output, err := reader.OpenOutput(c.output, stdout, false)
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql validate: error while opening file: %v\n", err)
+		return exitFailure
+	}
+	defer output.Close()
+
+	total, invalid, err := parseAll(input, m, func(index int, err error) {
+		fmt.Fprintf(output, "oplog %d: %v\n", index, err)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql validate: %v\n", err)
+		return exitFailure
+	}
+
+	fmt.Fprintf(output, "%d oplogs, %d invalid\n", total, invalid)
+	if invalid > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runSchema(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var c commonFlags
+	fs := newFlagSet("schema", stderr, &c)
+	if code, ok := parseFlags(fs, args, stderr); !ok {
+		return code
+	}
+
+	m, code := newParser(&c, "schema", stderr)
+	if m == nil {
+		return code
+	}
+
+	input, err := reader.OpenInput(c.input, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql schema: error while opening file: %v\n", err)
+		return exitFailure
+	}
+	defer input.Close()
+
+	// invalid oplogs are reported, but the schema is still written for the rest
+	_, invalid, err := parseAll(input, m, func(index int, err error) {
+		fmt.Fprintf(stderr, "oplog %d: %v\n", index, err)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql schema: %v\n", err)
+		return exitFailure
+	}
+
+	output, err := reader.OpenOutput(c.output, stdout, false)
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql schema: error while opening file: %v\n", err)
+		return exitFailure
+	}
+	defer output.Close()
+
+	fmt.Fprintln(output, strings.Join(m.GetSchemaStatements(), "\n"))
+	if invalid > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runSnapshot(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var c commonFlags
+	var format string
+	fs := newFlagSet("snapshot", stderr, &c)
+	fs.StringVar(&format, "format", "csv", "file format, csv or parquet, -output is the directory to write the files to")
+	if code, ok := parseFlags(fs, args, stderr); !ok {
+		return code
+	}
+
+	var sinkOpts []reader.SnapshotSinkOption
+	switch format {
+	case "csv":
+	case "parquet":
+		sinkOpts = append(sinkOpts, reader.WithParquet())
+	default:
+		fmt.Fprintf(stderr, "oplog2sql snapshot: unsupported format %q\n", format)
+		return exitUsage
+	}
+	if c.output == reader.Stdio {
+		fmt.Fprintf(stderr, "oplog2sql snapshot: -output directory is needed\n")
+		return exitUsage
+	}
+
+	parserOpts, err := c.parserOptions()
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql snapshot: %v\n", err)
+		return exitUsage
+	}
+
+	input, err := reader.OpenInput(c.input, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql snapshot: error while opening file: %v\n", err)
+		return exitFailure
+	}
+	defer input.Close()
+
+	sink, err := reader.NewSnapshotSink(c.output, sinkOpts...)
+	if err == nil {
+		err = reader.ReadFrom(input, sink, reader.WithParserOptions(parserOpts...))
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql snapshot: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func newParser(c *commonFlags, command string, stderr io.Writer) (*parser.MongoOplogParser, int) {
+	parserOpts, err := c.parserOptions()
+	if err != nil {
+		fmt.Fprintf(stderr, "oplog2sql %s: %v\n", command, err)
+		return nil, exitUsage
+	}
+	return parser.NewMongoOplogParser(parserOpts...), exitOK
+}
+
+// runs all the oplogs through the parser, calling onInvalid for the ones which fail
+// error is returned only if the input itself can't be decoded
+func parseAll(r io.Reader, m *parser.MongoOplogParser, onInvalid func(index int, err error)) (total, invalid int, err error) {
+	src := reader.NewJSONSource(r)
+	for {
+		obj, err := src.Next()
+		if err != nil {
+			if err == io.EOF {
+				return total, invalid, nil
+			}
+			return total, invalid, err
+		}
+
+		if _, err := m.GetEquivalentSQLStatements(string(obj)); err != nil {
+			onInvalid(total, err)
+			invalid++
+		}
+		total++
+	}
+}
+
+// checks if the output format can be used along with the other flags
+func checkFormat(format string, c *commonFlags, checkpointFile string) error {
+	switch format {
+	case "sql", "cdc":
+		return nil
+	case "copy", "csv":
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+
+	// bulk loading fails on existing rows, so it can't be replayed
+	if c.idempotent {
+		return fmt.Errorf("-idempotent can't be used with -format %s", format)
+	}
+	if format == "copy" {
+		if dialect, err := parser.ParseDialect(c.dialect); err == nil && dialect != parser.Postgres {
+			return fmt.Errorf("-format copy needs the postgres dialect")
+		}
+		return nil
+	}
+
+	// csv files and the manifest are written anew on every run
+	if c.output == reader.Stdio {
+		return fmt.Errorf("-format csv needs an output directory")
+	}
+	if checkpointFile != "" {
+		return fmt.Errorf("-checkpoint can't be used with -format csv")
+	}
+	return nil
+}
+
+// checks if the database can be used along with the other flags
+func checkDB(dbSpec, format string, c *commonFlags) error {
+	if dbSpec == "" {
+		return nil
+	}
+	driverName, _, ok := strings.Cut(dbSpec, ":")
+	if !ok || driverName == "" {
+		return fmt.Errorf("invalid -db %q: expected <driver>:<dsn>", dbSpec)
+	}
+	if format != "sql" {
+		return fmt.Errorf("-db can't be used with -format %s", format)
+	}
+	if c.output != reader.Stdio {
+		return fmt.Errorf("-db can't be used with -output")
+	}
+
+	// sqlite has no schemas, so it can't run the statements of the other dialects
+	if dialect, err := parser.ParseDialect(c.dialect); err == nil && driverName == "sqlite3" && dialect != parser.SQLite {
+		return fmt.Errorf("-db %s needs the sqlite dialect", driverName)
+	}
+	return nil
+}
+
+// returns the sink executing the statements against the database given as <driver>:<dsn>
+func openDBSink(ctx context.Context, dbSpec string, c *commonFlags) (reader.Sink, func() error, error) {
+	driverName, dsn, _ := strings.Cut(dbSpec, ":")
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while opening database: %v", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("error while connecting to database: %v", err)
+	}
+
+	// sqlite can't add a column only if it doesn't exist, which replaying idempotent statements needs
+	var opts []reader.DBSinkOption
+	if dialect, _ := parser.ParseDialect(c.dialect); c.idempotent && dialect == parser.SQLite {
+		opts = append(opts, reader.WithIgnoreDuplicateColumns())
+	}
+	return reader.NewDBSink(db, opts...), db.Close, nil
+}
+
+// returns the sink for the output format, writing to the output file, or the directory for csv
+func openSink(format, path string, stdout io.Writer, appendOutput, cdcSchema bool) (reader.Sink, func() error, error) {
+	if format == "csv" {
+		sink, err := reader.NewCSVSink(path)
+		return sink, func() error { return nil }, err
+	}
+
+	output, err := reader.OpenOutput(path, stdout, appendOutput)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while opening file: %v", err)
+	}
+	switch format {
+	case "copy":
+		return reader.NewCopySink(output), output.Close, nil
+	case "cdc":
+		var opts []reader.CDCSinkOption
+		if cdcSchema {
+			opts = append(opts, reader.WithCDCSchema())
+		}
+		return reader.NewCDCSink(output, opts...), output.Close, nil
+	default:
+		return reader.NewWriterSink(output), output.Close, nil
+	}
+}
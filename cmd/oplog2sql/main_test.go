@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	reader "github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/cmd"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testOplogs = `
+	{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller", "roll_no": 51}}
+	{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 52}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}}
+	{"op": "i", "ns": "test.student", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith", "phone": "+91-81254966457"}}
+`
+
+func TestRun(t *testing.T) {
+	tt := []struct {
+		name string
+		args []string
+		stdin string
+		expCode int
+		expStdout []string
+		expStderr []string
+	}{
+		{
+			name: "no command",
+			args: nil,
+			expCode: exitUsage,
+			expStderr: []string{"usage: oplog2sql"},
+		},
+		{
+			name: "unknown command",
+			args: []string{"export"},
+			expCode: exitUsage,
+			expStderr: []string{`unknown command "export"`},
+		},
+		{
+			name: "unknown flag",
+			args: []string{"convert", "-foo"},
+			expCode: exitUsage,
+		},
+		{
+			name: "unknown dialect",
+			args: []string{"convert", "-dialect", "oracle"},
+			expCode: exitUsage,
+			expStderr: []string{`unsupported dialect "oracle"`},
+		},
+		{
+			name: "convert from stdin to stdout",
+			args: []string{"convert"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{
+				"CREATE SCHEMA test;",
+				"INSERT INTO test.student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 51);",
+				"UPDATE test.student SET roll_no = 52 WHERE _id = '635b79e231d82a8ab1de863b';",
+				"ALTER TABLE test.student ADD phone  VARCHAR(255);",
+			},
+		},
+		{
+			name: "convert with workers",
+			args: []string{"convert", "-workers", "4"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{"UPDATE test.student SET roll_no = 52 WHERE _id = '635b79e231d82a8ab1de863b';ALTER TABLE test.student ADD phone  VARCHAR(255);"},
+		},
+		{
+			name: "convert with insert batching",
+			args: []string{"convert", "-insert-batch", "100"},
+			stdin: `{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}}
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}}`,
+			expCode: exitOK,
+			expStdout: []string{"INSERT INTO test.student (_id, name) VALUES ('1', 'Selena Miller'), ('2', 'George Smith');"},
+		},
+		{
+			name: "convert with compaction",
+			args: []string{"convert", "-compact", "-batch-size", "10"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{"INSERT INTO test.student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 52);"},
+		},
+		{
+			name: "convert to copy blocks",
+			args: []string{"convert", "-format", "copy", "-batch-size", "10"},
+			stdin: `{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}}
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}}`,
+			expCode: exitOK,
+			expStdout: []string{"COPY test.student (_id, name) FROM STDIN;\n1\tSelena Miller\n2\tGeorge Smith\n\\.\n"},
+		},
+		{
+			name: "convert to change events",
+			args: []string{"convert", "-format", "cdc"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{`"after":{"_id":"635b79e231d82a8ab1de863b","roll_no":52},"op":"u"`},
+		},
+		{
+			name: "snapshot without output directory",
+			args: []string{"snapshot"},
+			expCode: exitUsage,
+			expStderr: []string{"-output directory is needed"},
+		},
+		{
+			name: "snapshot in unknown format",
+			args: []string{"snapshot", "-format", "orc", "-output", "out"},
+			expCode: exitUsage,
+			expStderr: []string{`unsupported format "orc"`},
+		},
+		{
+			name: "database without driver",
+			args: []string{"convert", "-db", "replica.db"},
+			expCode: exitUsage,
+			expStderr: []string{"expected <driver>:<dsn>"},
+		},
+		{
+			name: "sqlite database with postgres dialect",
+			args: []string{"convert", "-db", "sqlite3:replica.db"},
+			expCode: exitUsage,
+			expStderr: []string{"-db sqlite3 needs the sqlite dialect"},
+		},
+		{
+			name: "database with cdc format",
+			args: []string{"convert", "-db", "sqlite3:replica.db", "-dialect", "sqlite", "-format", "cdc"},
+			expCode: exitUsage,
+			expStderr: []string{"-db can't be used with -format cdc"},
+		},
+		{
+			name: "database with unknown driver",
+			args: []string{"convert", "-db", "oracle:replica", "-dialect", "sqlite"},
+			expCode: exitFailure,
+			expStderr: []string{"error while opening database"},
+		},
+		{
+			name: "unknown format",
+			args: []string{"convert", "-format", "xml"},
+			expCode: exitUsage,
+			expStderr: []string{`unsupported format "xml"`},
+		},
+		{
+			name: "copy format with sqlite dialect",
+			args: []string{"convert", "-format", "copy", "-dialect", "sqlite"},
+			expCode: exitUsage,
+			expStderr: []string{"-format copy needs the postgres dialect"},
+		},
+		{
+			name: "csv format without output directory",
+			args: []string{"convert", "-format", "csv"},
+			expCode: exitUsage,
+			expStderr: []string{"-format csv needs an output directory"},
+		},
+		{
+			name: "bulk format with idempotent",
+			args: []string{"convert", "-format", "csv", "-output", "out", "-idempotent"},
+			expCode: exitUsage,
+			expStderr: []string{"-idempotent can't be used with -format csv"},
+		},
+		{
+			name: "convert with sqlite dialect",
+			args: []string{"convert", "-dialect", "sqlite", "-idempotent"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{"CREATE TABLE IF NOT EXISTS test_student", "ON CONFLICT (_id) DO UPDATE"},
+		},
+		{
+			name: "convert filtered namespaces",
+			args: []string{"convert", "-ns", "test.*", "-ns", "!test.student"},
+			stdin: testOplogs + `{"op": "i", "ns": "test.teacher", "o": {"_id": "1", "name": "Jane Doe"}}`,
+			expCode: exitOK,
+			expStdout: []string{"INSERT INTO test.teacher (_id, name) VALUES ('1', 'Jane Doe');"},
+		},
+		{
+			name: "invalid namespace rule",
+			args: []string{"convert", "-ns", "test.["},
+			expCode: exitUsage,
+			expStderr: []string{`invalid namespace rule "test.["`},
+		},
+		{
+			name: "convert mapped namespaces",
+			args: []string{"convert", "-map", "test.student=school.Students", "-db-prefix", "mongo_", "-lowercase"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{"CREATE SCHEMA mongo_school;", "UPDATE mongo_school.students SET roll_no = 52"},
+		},
+		{
+			name: "invalid namespace mapping",
+			args: []string{"convert", "-map", "test.student"},
+			expCode: exitUsage,
+			expStderr: []string{`invalid namespace mapping "test.student"`},
+		},
+		{
+			name: "convert with field rules",
+			args: []string{"convert", "-drop", "test.student:phone", "-rename", "test.*:roll_no=roll", "-mask", "test.student:name"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{
+				"INSERT INTO test.student (_id, name, roll) VALUES ('635b79e231d82a8ab1de863b', '772b914e39dfb41aea2b151f0e22b3c518f9cea4c2dd09c2e510c44f83387463', 51);",
+				"UPDATE test.student SET roll = 52",
+			},
+		},
+		{
+			name: "invalid field rule",
+			args: []string{"convert", "-rename", "test.student:roll_no"},
+			expCode: exitUsage,
+			expStderr: []string{`invalid field rule "test.student:roll_no"`},
+		},
+		{
+			name: "convert invalid oplog",
+			args: []string{"convert"},
+			stdin: `{"op": "i", "ns": "student", "o": {"_id": "1"}}`,
+			expCode: exitFailure,
+			expStderr: []string{"invalid namespace"},
+		},
+		{
+			name: "convert from invalid mongo uri",
+			args: []string{"convert", "-mongo-uri", "invalid://localhost"},
+			expCode: exitFailure,
+			expStderr: []string{"error while connecting to mongodb"},
+		},
+		{
+			name: "validate valid oplogs",
+			args: []string{"validate"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{"3 oplogs, 0 invalid"},
+		},
+		{
+			name: "validate invalid oplogs",
+			args: []string{"validate"},
+			stdin: testOplogs + `{"op": "n", "ns": "", "o": {"msg": "periodic noop"}}`,
+			expCode: exitFailure,
+			expStdout: []string{"oplog 3: error: unsupported operation type", "4 oplogs, 1 invalid"},
+		},
+		{
+			name: "schema",
+			args: []string{"schema"},
+			stdin: testOplogs,
+			expCode: exitOK,
+			expStdout: []string{"CREATE SCHEMA test;\nCREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), phone  VARCHAR(255), roll_no  FLOAT);\n"},
+		},
+		{
+			name: "malformed json",
+			args: []string{"validate"},
+			stdin: `{"op": `,
+			expCode: exitFailure,
+			expStderr: []string{"error while decoding json"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr)
+
+			if code != tc.expCode {
+				t.Errorf("Expected exit code %d but got %d, stderr: %q", tc.expCode, code, stderr.String())
+			}
+			for _, exp := range tc.expStdout {
+				if !strings.Contains(stdout.String(), exp) {
+					t.Errorf("Expected stdout to contain %q but got %q", exp, stdout.String())
+				}
+			}
+			for _, exp := range tc.expStderr {
+				if !strings.Contains(stderr.String(), exp) {
+					t.Errorf("Expected stderr to contain %q but got %q", exp, stderr.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunConvertFiles(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "output.sql")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"convert", "-input", "../../testdata/oplog.json", "-output", outputFile}, strings.NewReader(""), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d but got %d, stderr: %q", exitOK, code, stderr.String())
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Error while reading output file: %v", err)
+	}
+	if !strings.Contains(string(data), "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';") {
+		t.Errorf("Expected delete statement in output but got %q", data)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected nothing on stdout but got %q", stdout.String())
+	}
+}
+
+func TestRunConvertToCSV(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "csv")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"convert", "-format", "csv", "-input", "../../testdata/oplog.json", "-output", outputDir}, strings.NewReader(""), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d but got %d, stderr: %q", exitOK, code, stderr.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "test.student.1.csv"))
+	if err != nil {
+		t.Fatalf("Error while reading csv file: %v", err)
+	}
+	exp := "_id,date_of_birth,is_graduated,name,roll_no\n\"635b79e231d82a8ab1de863b\",\"2000-01-30\",false,\"Selena Miller\",51\n"
+	if string(data) != exp {
+		t.Errorf("Expected %q but got %q", exp, data)
+	}
+
+	data, err = os.ReadFile(filepath.Join(outputDir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("Error while reading manifest: %v", err)
+	}
+	if !strings.Contains(string(data), "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';") {
+		t.Errorf("Expected delete statement in manifest but got %q", data)
+	}
+}
+
+func TestRunConvertToDB(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "replica.db")
+
+	// idempotent statements are run twice, adding the columns which exist is left to the sink
+	for i := 0; i < 2; i++ {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"convert", "-db", "sqlite3:" + dbFile, "-dialect", "sqlite", "-idempotent"}, strings.NewReader(testOplogs), &stdout, &stderr)
+		if code != exitOK {
+			t.Fatalf("Expected exit code %d but got %d, stderr: %q", exitOK, code, stderr.String())
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("Expected nothing on stdout but got %q", stdout.String())
+		}
+	}
+
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		t.Fatalf("Error while opening database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	var rollNo float64
+	if err := db.QueryRow("SELECT COUNT(*), MAX(roll_no) FROM test_student").Scan(&count, &rollNo); err != nil {
+		t.Fatalf("Error while querying: %v", err)
+	}
+	if count != 2 || rollNo != 52 {
+		t.Errorf("Expected 2 rows with roll_no 52 but got %d rows with %v", count, rollNo)
+	}
+}
+
+func TestRunSnapshot(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "snapshot")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"snapshot", "-output", outputDir}, strings.NewReader(testOplogs), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d but got %d, stderr: %q", exitOK, code, stderr.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "test.student.csv"))
+	if err != nil {
+		t.Fatalf("Error while reading snapshot: %v", err)
+	}
+	exp := "_id,name,phone,roll_no\n\"635b79e231d82a8ab1de863b\",\"Selena Miller\",,52\n\"14798c213f273a7ca2cf5174\",\"George Smith\",\"+91-81254966457\",\n"
+	if string(data) != exp {
+		t.Errorf("Expected %q but got %q", exp, data)
+	}
+}
+
+func TestDescribeProgress(t *testing.T) {
+	tt := []struct {
+		name string
+		progress reader.Progress
+		expected string
+	}{
+		{"nothing converted", reader.Progress{Index: -1}, "no oplogs converted"},
+		{"without ts", reader.Progress{Index: 2}, "converted up to oplog 2"},
+		{"with ts", reader.Progress{Index: 5, Ts: primitive.Timestamp{T: 1700000000, I: 3}, HasTs: true}, "converted up to oplog 5 (ts 1700000000.3)"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := describeProgress(tc.progress)
+			if got != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestListFlag(t *testing.T) {
+	tt := []struct {
+		name string
+		values []string
+		exp []string
+	}{
+		{name: "repeated", values: []string{"test.student", "test.teacher"}, exp: []string{"test.student", "test.teacher"}},
+		{name: "comma separated", values: []string{"test.student, test.teacher,"}, exp: []string{"test.student", "test.teacher"}},
+		{name: "regex with comma", values: []string{`/^test\.a{1,2}$/`}, exp: []string{`/^test\.a{1,2}$/`}},
+		{name: "excluded regex with comma", values: []string{`test.*,!/^test\.a{1,2}$/,test.b`}, exp: []string{"test.*", `!/^test\.a{1,2}$/`, "test.b"}},
+		{name: "regex with escaped slash", values: []string{`/a\/b,c/,d`}, exp: []string{`/a\/b,c/`, "d"}},
+		{name: "field rule on regex", values: []string{`/^test\.(a|b){1,2}$/:email,test.c:email`}, exp: []string{`/^test\.(a|b){1,2}$/:email`, "test.c:email"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var l listFlag
+			for _, v := range tc.values {
+				if err := l.Set(v); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if !slices.Equal(tc.exp, []string(l)) {
+				t.Errorf("Expected %q but got %q", tc.exp, l)
+			}
+		})
+	}
+}
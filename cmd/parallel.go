@@ -0,0 +1,119 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+var errStopped = errors.New("error: conversion stopped")
+
+// oplog queued for the workers, the result is sent on the channel
+type parallelJob struct {
+	index int
+	raw json.RawMessage
+	result chan<- *preparedOplog
+}
+
+// entry in the queue of results, kept in the order of the oplogs
+type parallelResult struct {
+	result <-chan *preparedOplog
+	idle bool						// source is waiting for more oplogs
+	err error						// source failed
+}
+
+// converts the oplogs of the source with a pool of workers which parse them concurrently,
+// while the parsed oplogs are handled in their original order by the calling goroutine,
+// until ctx is done
+func(c *converter) convertParallel(ctx context.Context, src Source, workers int) error {
+	// reader is waited for before returning, so that the source is not closed while being
+	// read, which takes until the source returns, i.e. for the followed file till the next poll
+	done := make(chan struct{})
+	readerDone := make(chan struct{})
+	defer func() {
+		close(done)
+		<-readerDone
+	}()
+
+	jobs := make(chan parallelJob, workers)
+	results := make(chan parallelResult, workers*4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job.result <- c.prepare(ctx, job.index, job.raw)
+			}
+		}()
+	}
+
+	// handing over the pending statements while waiting for more oplogs, after the
+	// oplogs read so far, by queueing a marker for it
+	if n, ok := src.(idleNotifier); ok {
+		n.setOnIdle(func() error {
+			select {
+			case results <- parallelResult{idle: true}:
+				return nil
+			case <-done:
+				return errStopped
+			}
+		})
+	}
+
+	// reading the oplogs, queueing each for the workers along with its place in the results
+	go func() {
+		defer close(readerDone)
+		defer close(results)
+		defer wg.Wait()
+		defer close(jobs)
+
+		for ctx.Err() == nil {
+			raw, err := src.Next()
+			if err != nil {
+				if err != io.EOF {
+					select {
+					case results <- parallelResult{err: err}:
+					case <-done:
+					}
+				}
+				return
+			}
+
+			result := make(chan *preparedOplog, 1)
+			select {
+			case jobs <- parallelJob{index: c.next(), raw: raw, result: result}:
+			case <-done:
+				return
+			}
+			select {
+			case results <- parallelResult{result: result}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for r := range results {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch {
+		case r.err != nil:
+			return r.err
+		case r.idle:
+			if err := c.flush(); err != nil {
+				return err
+			}
+		default:
+			if err := c.handle(<-r.result); err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.Err()
+}
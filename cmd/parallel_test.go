@@ -0,0 +1,122 @@
+package reader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+)
+
+// generates oplogs for a few collections, where each document is inserted, updated
+// and some of them deleted, with new columns and nested objects showing up midway
+func generateOplogs(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		ns := fmt.Sprintf("test.coll_%d", i%4)
+		id := fmt.Sprintf("%024x", i/3)
+		ts := fmt.Sprintf(`{"$timestamp": {"t": %d, "i": 1}}`, 1700000000+i)
+		switch i % 3 {
+		case 0:
+			extra := ""
+			if i > n/2 {
+				extra = fmt.Sprintf(`, "score_%d": %d, "phone": {"work": "%d"}, "address": [{"zip": "%d"}, {"zip": "%d"}]`, i%5, i, i, i, i+1)
+			}
+			fmt.Fprintf(&b, `{"op": "i", "ns": "%s", "ts": %s, "o": {"_id": "%s", "name": "Student %d", "roll_no": %d%s}}`+"\n", ns, ts, id, i, i, extra)
+		case 1:
+			fmt.Fprintf(&b, `{"op": "u", "ns": "%s", "ts": %s, "o": {"$v": 2, "diff": {"u": {"roll_no": %d}}}, "o2": {"_id": "%s"}}`+"\n", ns, ts, i, id)
+		default:
+			if i%2 == 0 {
+				fmt.Fprintf(&b, `{"op": "d", "ns": "%s", "ts": %s, "o": {"_id": "%s"}}`+"\n", ns, ts, id)
+			} else {
+				fmt.Fprintf(&b, `{"op": "n", "ns": "", "ts": %s, "o": {"msg": "periodic noop"}}`+"\n", ts)
+			}
+		}
+	}
+	return b.String()
+}
+
+func TestReadFromSourceWithWorkers(t *testing.T) {
+	input := generateOplogs(3000)
+
+	convert := func(opts ...Option) (string, string) {
+		var out, deadLetter bytes.Buffer
+		opts = append(opts, WithParserOptions(parser.WithIdempotent()), WithDeadLetterWriter(&deadLetter))
+		if err := ReadFrom(strings.NewReader(input), NewWriterSink(&out), opts...); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return out.String(), deadLetter.String()
+	}
+
+	expOut, expDeadLetter := convert()
+	for _, workers := range []int{2, 8} {
+		for _, batchSize := range []int{1, 50} {
+			gotOut, gotDeadLetter := convert(WithWorkers(workers), WithBatchSize(batchSize))
+			if gotOut != expOut {
+				t.Errorf("Expected the same output with %d workers and batch size %d", workers, batchSize)
+			}
+			if gotDeadLetter != expDeadLetter {
+				t.Errorf("Expected the same dead letter with %d workers and batch size %d", workers, batchSize)
+			}
+		}
+	}
+}
+
+func TestReadFromSourceWithWorkersStopsOnError(t *testing.T) {
+	input := generateOplogs(3000)
+
+	var out bytes.Buffer
+	err := ReadFrom(strings.NewReader(input), NewWriterSink(&out), WithWorkers(4))
+	if err == nil || !strings.Contains(err.Error(), "unsupported operation type") {
+		t.Errorf("Expected error for the noop but got %v", err)
+	}
+}
+
+func TestReadToSinkWithFollowAndWorkers(t *testing.T) {
+	inputFile := filepath.Join(t.TempDir(), "oplog.json")
+	appendToFile(t, inputFile, `{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}}`+"\n")
+
+	sink := &collectSink{}
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- ReadToSink(inputFile, sink, WithFollow(10*time.Millisecond, stop), WithBatchSize(100), WithWorkers(4))
+	}()
+
+	// pending statements are handed over while waiting, after the oplogs before
+	waitForStatement(t, sink, "VALUES ('1', 'Selena Miller')")
+	appendToFile(t, inputFile, `{"op": "d", "ns": "test.student", "o": {"_id": "1"}}`+"\n")
+	waitForStatement(t, sink, "DELETE FROM test.student WHERE _id = '1';")
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for read to stop")
+	}
+}
+
+// most of the time goes into parsing the oplogs, which is what the workers share,
+// so throughput of the sub-benchmarks scales with the number of workers up to runtime.NumCPU()
+func BenchmarkReadFromSource(b *testing.B) {
+	input := generateOplogs(10000)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				err := ReadFrom(strings.NewReader(input), NewWriterSink(io.Discard), WithWorkers(workers), WithDeadLetterWriter(io.Discard))
+				if err != nil {
+					b.Fatalf("Unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package reader
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Sink receives the sql statements generated for the oplogs
+type Sink interface {
+	// Write receives the statements of one or more oplogs, grouped per oplog
+	// unless the statements across them are merged, see WithInsertBatching and WithCompaction
+	Write(batch [][]string) error
+	// Close finishes the output, i.e. writes what the sink keeps till the end. The writers
+	// and databases handed over to the sinks are owned by the caller, so they are not closed.
+	Close() error
+}
+
+// WriterSink writes the sql statements to an io.Writer as it is
+type WriterSink struct {
+	w io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func(ws *WriterSink) Write(batch [][]string) error {
+	for _, stmts := range batch {
+		if _, err := io.WriteString(ws.w, strings.Join(stmts, "")); err != nil {
+			return fmt.Errorf("error while writing sql: %v", err)
+		}
+	}
+	return nil
+}
+
+func(ws *WriterSink) Close() error {
+	return nil
+}
@@ -0,0 +1,213 @@
+package reader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/replay"
+	"github.com/parquet-go/parquet-go"
+)
+
+// SnapshotSink keeps the final state of each table in memory, applying the statements to
+// a replay.Engine as they come, and writes it to a file per table in a directory on Close,
+// named <db>.<table>.csv, or .parquet with WithParquet. Nested object tables are written the
+// same way. The engine is lenient, so a row inserted again replaces the one with the same _id,
+// and the columns are created as they are used. Rows are written in the order they were first
+// inserted, columns are sorted by name, and a column holding values of different types is
+// written as strings. Nothing is written for the tables which are empty in the end.
+type SnapshotSink struct {
+	dir string
+	parquet bool
+	engine *replay.Engine
+}
+
+// SnapshotSinkOption configures the behaviour of SnapshotSink
+type SnapshotSinkOption func(*SnapshotSink)
+
+// WithParquet makes SnapshotSink write parquet files instead of csv
+func WithParquet() SnapshotSinkOption {
+	return func(ss *SnapshotSink) {
+		ss.parquet = true
+	}
+}
+
+// NewSnapshotSink returns a sink writing to the given directory, which is created if needed
+func NewSnapshotSink(dir string, opts ...SnapshotSinkOption) (*SnapshotSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error while creating snapshot directory: %v", err)
+	}
+	ss := &SnapshotSink{dir: dir, engine: replay.NewEngine(replay.WithLenient())}
+	for _, opt := range opts {
+		opt(ss)
+	}
+	return ss, nil
+}
+
+// Write can't apply the statements, as the rows can't be told from the sql alone
+func(ss *SnapshotSink) Write(batch [][]string) error {
+	return fmt.Errorf("error: snapshot needs the statements, not just the sql")
+}
+
+func(ss *SnapshotSink) WriteStatements(batch [][]parser.Statement) error {
+	for _, stmts := range batch {
+		if err := ss.engine.Apply(stmts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the sql type of each column, i.e. FLOAT, or an empty string
+// for the columns holding values of different types
+func getSnapshotColumnTypes(rows []replay.Row, cols []string) map[string]string {
+	types := make(map[string]string, len(cols))
+	for _, col := range cols {
+		colType, mixed := "", false
+		for _, row := range rows {
+			valType := parser.GetColumnType(row[col])
+			if valType == "" {
+				continue
+			}
+			if colType != "" && colType != valType {
+				mixed = true
+				break
+			}
+			colType = valType
+		}
+		if mixed {
+			colType = ""
+		}
+		types[col] = colType
+	}
+	return types
+}
+
+// Close writes the file for each of the tables
+func(ss *SnapshotSink) Close() error {
+	for _, t := range ss.engine.Tables() {
+		rows, cols := t.Rows(), t.Columns()
+		if len(rows) == 0 {
+			continue
+		}
+		key := t.DBName + "." + t.TableName
+
+		var err error
+		if ss.parquet {
+			err = writeParquetSnapshot(filepath.Join(ss.dir, key + ".parquet"), rows, cols)
+		} else {
+			err = writeCSVSnapshot(filepath.Join(ss.dir, key + ".csv"), rows, cols)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSVSnapshot(path string, rows []replay.Row, cols []string) error {
+	types := getSnapshotColumnTypes(rows, cols)
+
+	var b strings.Builder
+	b.WriteString(strings.Join(cols, ",") + "\n")
+	for _, row := range rows {
+		vals := make([]string, 0, len(cols))
+		for _, col := range cols {
+			val := row[col]
+			if types[col] == "" && val != nil {
+				val = snapshotString(val)
+			}
+			vals = append(vals, csvValue(val))
+		}
+		b.WriteString(strings.Join(vals, ",") + "\n")
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("error while writing snapshot: %v", err)
+	}
+	return nil
+}
+
+func writeParquetSnapshot(path string, rows []replay.Row, cols []string) error {
+	types := getSnapshotColumnTypes(rows, cols)
+
+	// all the columns are optional, as any of them may be missing from a row
+	group := parquet.Group{}
+	for _, col := range cols {
+		switch types[col] {
+		case "FLOAT":
+			group[col] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		case "BOOLEAN":
+			group[col] = parquet.Optional(parquet.Leaf(parquet.BooleanType))
+		default:
+			group[col] = parquet.Optional(parquet.String())
+		}
+	}
+	schema := parquet.NewSchema(strings.TrimSuffix(filepath.Base(path), ".parquet"), group)
+
+	parquetRows := make([]parquet.Row, 0, len(rows))
+	for _, row := range rows {
+		vals := make(map[string]interface{}, len(cols))
+		for _, col := range cols {
+			val, err := getParquetValue(row[col], types[col])
+			if err != nil {
+				return fmt.Errorf("error while writing snapshot: column %s: %v", col, err)
+			}
+			vals[col] = val
+		}
+		parquetRows = append(parquetRows, schema.Deconstruct(nil, vals))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error while creating snapshot: %v", err)
+	}
+	defer f.Close()
+
+	w := parquet.NewWriter(f, schema)
+	if _, err := w.WriteRows(parquetRows); err != nil {
+		return fmt.Errorf("error while writing snapshot: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error while writing snapshot: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error while writing snapshot: %v", err)
+	}
+	return nil
+}
+
+// returns the value as per the type of the parquet column, so that Deconstruct doesn't panic
+// integers, i.e. $numberInt and $numberLong, are written as doubles along with the other numbers
+func getParquetValue(val interface{}, colType string) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	switch colType {
+	case "FLOAT":
+		switch v := val.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		}
+	case "BOOLEAN":
+		if v, ok := val.(bool); ok {
+			return v, nil
+		}
+	default:
+		return snapshotString(val), nil
+	}
+	return nil, fmt.Errorf("unexpected value %v of type %T for %s", val, val, colType)
+}
+
+// formats the value of a column holding values of different types
+func snapshotString(val interface{}) string {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return plainValue(val)
+}
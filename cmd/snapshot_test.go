@@ -0,0 +1,120 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+	"github.com/parquet-go/parquet-go"
+)
+
+const snapshotOplogs = `
+	{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51, "address": [{"zip": "89799"}, {"zip": "12345"}]}}
+	{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith", "roll_no": "52", "is_graduated": true}}
+	{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe", "roll_no": 53}}
+	{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena Smith"}, "d": {"roll_no": false}}}, "o2": {"_id": "1"}}
+	{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"is_graduated": false}}}, "o2": {"_id": "3"}}
+	{"op": "d", "ns": "test.student", "o": {"_id": "2"}}
+	{"op": "i", "ns": "test.teacher", "o": {"_id": "4", "name": "John Doe"}}
+	{"op": "d", "ns": "test.teacher", "o": {"name": "John Doe"}}
+`
+
+func TestReadFromToSnapshotSink(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	sink, err := NewSnapshotSink(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// ids of the nested object rows are derived from the parent _id in idempotent mode
+	err = ReadFrom(strings.NewReader(snapshotOplogs), sink, WithParserOptions(parser.WithIdempotent()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Error while reading snapshot directory: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if exp := []string{"test.student.csv", "test.student_address.csv"}; !reflect.DeepEqual(exp, names) {
+		t.Errorf("Expected files %q but got %q", exp, names)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "test.student.csv"))
+	if err != nil {
+		t.Fatalf("Error while reading snapshot: %v", err)
+	}
+	exp := "_id,is_graduated,name,roll_no\n\"1\",,\"Selena Smith\",\n\"3\",false,\"Jane Doe\",53\n"
+	if string(data) != exp {
+		t.Errorf("Expected %q but got %q", exp, data)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "test.student_address.csv"))
+	if err != nil {
+		t.Fatalf("Error while reading snapshot: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != "_id,student__id,zip" || !strings.HasSuffix(lines[1], `,"1","89799"`) || !strings.HasSuffix(lines[2], `,"1","12345"`) {
+		t.Errorf("Expected two rows for the nested objects but got %q", lines)
+	}
+}
+
+func TestSnapshotSinkParquet(t *testing.T) {
+	input := `
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51, "credits": {"$numberLong": "120"}}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith", "roll_no": "52", "is_graduated": true}}
+		{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe", "score": 9.5, "credits": {"$numberInt": "90"}}}
+	`
+	dir := t.TempDir()
+	sink, err := NewSnapshotSink(dir, WithParquet())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := ReadFrom(strings.NewReader(input), sink); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "test.student.parquet"))
+	if err != nil {
+		t.Fatalf("Error while opening snapshot: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Error while opening snapshot: %v", err)
+	}
+	pf, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		t.Fatalf("Error while opening snapshot: %v", err)
+	}
+
+	// roll_no has both numbers and strings, so it is written as strings
+	// integers of extended json are written as doubles, same as the other numbers
+	expSchema := "message test.student {\n\toptional binary _id (STRING);\n\toptional double credits;\n\toptional boolean is_graduated;\n\toptional binary name (STRING);\n\toptional binary roll_no (STRING);\n\toptional double score;\n}"
+	if got := pf.Schema().String(); got != expSchema {
+		t.Errorf("Expected schema %q but got %q", expSchema, got)
+	}
+
+	exp := []map[string]interface{}{
+		{"_id": "1", "credits": 120.0, "is_graduated": nil, "name": "Selena Miller", "roll_no": "51", "score": nil},
+		{"_id": "2", "credits": nil, "is_graduated": true, "name": "George Smith", "roll_no": "52", "score": nil},
+		{"_id": "3", "credits": 90.0, "is_graduated": nil, "name": "Jane Doe", "roll_no": nil, "score": 9.5},
+	}
+	r := parquet.NewReader(pf)
+	for _, expRow := range exp {
+		row := make(map[string]interface{})
+		if err := r.Read(&row); err != nil {
+			t.Fatalf("Error while reading row: %v", err)
+		}
+		if !reflect.DeepEqual(expRow, row) {
+			t.Errorf("Expected row %v but got %v", expRow, row)
+		}
+	}
+}
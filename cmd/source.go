@@ -0,0 +1,105 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Source yields the raw oplogs to be converted, one at a time
+type Source interface {
+	// Next returns the next raw oplog, or io.EOF once there are no more
+	Next() (json.RawMessage, error)
+	Close() error
+}
+
+// idleNotifier is implemented by the sources which wait for more oplogs instead of
+// ending, onIdle is called before waiting so that pending statements reach the sink
+type idleNotifier interface {
+	setOnIdle(onIdle func() error)
+}
+
+// resumer is implemented by the sources which can start after the checkpoint on their own,
+// resumed tells if they did, so that the oplogs they yield are not checked against it
+type resumer interface {
+	resumed() bool
+}
+
+// jsonSource reads the oplogs from a stream of json values
+type jsonSource struct {
+	r io.Reader
+	decoder *json.Decoder
+}
+
+// NewJSONSource returns a Source which reads the oplogs from r, which can hold
+// json values one after another, e.g. JSONL, or json arrays of them.
+// r is not closed by the source.
+func NewJSONSource(r io.Reader) Source {
+	return &jsonSource{
+		r: r,
+		decoder: json.NewDecoder(r),
+	}
+}
+
+func(s *jsonSource) Next() (json.RawMessage, error) {
+	var obj json.RawMessage
+	if err := s.decoder.Decode(&obj); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error while decoding json: %v", err)
+	}
+	return obj, nil
+}
+
+func(s *jsonSource) setOnIdle(onIdle func() error) {
+	if fr, ok := s.r.(*followReader); ok {
+		fr.onIdle = onIdle
+	}
+}
+
+func(s *jsonSource) Close() error {
+	return nil
+}
+
+// lineSource reads the oplogs from JSONL, one json value per line. Lines which are not valid
+// json are yielded as they are, so that they fail like the invalid oplogs do, i.e. go to the
+// dead letter, instead of stopping the reading at them.
+type lineSource struct {
+	r io.Reader
+	br *bufio.Reader
+}
+
+func newLineSource(r io.Reader) Source {
+	return &lineSource{
+		r: r,
+		br: bufio.NewReader(r),
+	}
+}
+
+func(s *lineSource) Next() (json.RawMessage, error) {
+	for {
+		line, err := s.br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("error while reading input: %v", err)
+		}
+	}
+}
+
+func(s *lineSource) setOnIdle(onIdle func() error) {
+	if fr, ok := s.r.(*followReader); ok {
+		fr.onIdle = onIdle
+	}
+}
+
+func(s *lineSource) Close() error {
+	return nil
+}
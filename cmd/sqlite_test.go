@@ -0,0 +1,252 @@
+package reader
+
+import (
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/replay"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// engineSink applies the statements to a replay.Engine
+type engineSink struct {
+	engine *replay.Engine
+}
+
+func(es *engineSink) Write(batch [][]string) error {
+	return nil
+}
+
+func(es *engineSink) WriteStatements(batch [][]parser.Statement) error {
+	for _, stmts := range batch {
+		if err := es.engine.Apply(stmts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func(es *engineSink) Close() error {
+	return nil
+}
+
+// TestReplayFixturesOnSQLite runs the sql generated for each testdata/<case>/input.json
+// against sqlite, and checks the rows left in each table against expected.rows.json.
+// Each case is run with and without idempotent mode. Ids of the rows in nested object
+// tables, i.e. the ones with a <parent>__id column, are random without it, so they are
+// only compared in idempotent mode. The same rows are expected from the replay engine.
+func TestReplayFixturesOnSQLite(t *testing.T) {
+	cases, err := filepath.Glob("../testdata/*/expected.rows.json")
+	if err != nil {
+		t.Fatalf("Error while listing fixtures: %v", err)
+	}
+	if len(cases) == 0 {
+		t.Fatalf("Expected fixtures in testdata")
+	}
+
+	for _, expFile := range cases {
+		dir := filepath.Dir(expFile)
+		data, err := os.ReadFile(expFile)
+		if err != nil {
+			t.Fatalf("Error while reading fixture: %v", err)
+		}
+		var exp map[string][]map[string]interface{}
+		if err := json.Unmarshal(data, &exp); err != nil {
+			t.Fatalf("Error while decoding %s: %v", expFile, err)
+		}
+
+		for _, idempotent := range []bool{false, true} {
+			name := filepath.Base(dir)
+			parserOpts := []parser.Option{parser.WithDialect(parser.SQLite)}
+			var engineOpts []replay.Option
+			if idempotent {
+				name += "/idempotent"
+				parserOpts = append(parserOpts, parser.WithIdempotent())
+				engineOpts = append(engineOpts, replay.WithIdempotent())
+			}
+
+			t.Run(name, func(t *testing.T) {
+				inputFile := filepath.Join(dir, "input.json")
+				db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+				if err != nil {
+					t.Fatalf("Error while opening database: %v", err)
+				}
+				defer db.Close()
+
+				if err := ReadToSink(inputFile, NewDBSink(db), WithParserOptions(parserOpts...)); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				got := getSQLiteRows(t, db)
+				compareFixtureRows(t, "sqlite", exp, got, idempotent)
+
+				engine := replay.NewEngine(engineOpts...)
+				if err := ReadToSink(inputFile, &engineSink{engine: engine}, WithParserOptions(parserOpts...)); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				got = make(map[string][]map[string]interface{})
+				for _, table := range engine.Tables() {
+					for _, row := range table.Rows() {
+						got[table.DBName + "_" + table.TableName] = append(got[table.DBName + "_" + table.TableName], row)
+					}
+				}
+				compareFixtureRows(t, "replay", exp, got, idempotent)
+			})
+		}
+	}
+}
+
+// TestReplayFixturesTwiceOnSQLite runs the sql generated in idempotent mode for each case twice
+// against the same database, which has to leave the same rows as running it once
+func TestReplayFixturesTwiceOnSQLite(t *testing.T) {
+	cases, err := filepath.Glob("../testdata/*/expected.rows.json")
+	if err != nil {
+		t.Fatalf("Error while listing fixtures: %v", err)
+	}
+
+	for _, expFile := range cases {
+		dir := filepath.Dir(expFile)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			data, err := os.ReadFile(expFile)
+			if err != nil {
+				t.Fatalf("Error while reading fixture: %v", err)
+			}
+			var exp map[string][]map[string]interface{}
+			if err := json.Unmarshal(data, &exp); err != nil {
+				t.Fatalf("Error while decoding %s: %v", expFile, err)
+			}
+
+			db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatalf("Error while opening database: %v", err)
+			}
+			defer db.Close()
+
+			inputFile := filepath.Join(dir, "input.json")
+			for run := 1; run <= 2; run++ {
+				err := ReadToSink(inputFile, NewDBSink(db, WithIgnoreDuplicateColumns()), WithParserOptions(parser.WithDialect(parser.SQLite), parser.WithIdempotent()))
+				if err != nil {
+					t.Fatalf("Unexpected error on run %d: %v", run, err)
+				}
+			}
+			compareFixtureRows(t, "sqlite", exp, getSQLiteRows(t, db), true)
+		})
+	}
+}
+
+// returns the rows of each table, leaving out the null columns
+func getSQLiteRows(t *testing.T, db *sql.DB) map[string][]map[string]interface{} {
+	t.Helper()
+
+	tableRows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name")
+	if err != nil {
+		t.Fatalf("Error while listing tables: %v", err)
+	}
+	var tables []string
+	for tableRows.Next() {
+		var table string
+		if err := tableRows.Scan(&table); err != nil {
+			t.Fatalf("Error while scanning: %v", err)
+		}
+		tables = append(tables, table)
+	}
+	tableRows.Close()
+
+	result := make(map[string][]map[string]interface{})
+	for _, table := range tables {
+		rows, err := db.Query("SELECT * FROM " + table)
+		if err != nil {
+			t.Fatalf("Error while querying %s: %v", table, err)
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatalf("Error while querying %s: %v", table, err)
+		}
+		for rows.Next() {
+			vals := make([]interface{}, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range vals {
+				ptrs[i] = &vals[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				t.Fatalf("Error while scanning %s: %v", table, err)
+			}
+
+			row := make(map[string]interface{})
+			for i, col := range cols {
+				switch v := vals[i].(type) {
+				case nil:
+				case []byte:
+					row[col] = string(v)
+				case int64:
+					row[col] = float64(v)
+				default:
+					row[col] = v
+				}
+			}
+			result[table] = append(result[table], row)
+		}
+		rows.Close()
+	}
+	return result
+}
+
+// compares the rows of each table regardless of their order, tables without rows are left out
+func compareFixtureRows(t *testing.T, name string, exp, got map[string][]map[string]interface{}, withNestedIds bool) {
+	t.Helper()
+
+	normalize := func(tables map[string][]map[string]interface{}) map[string][]string {
+		result := make(map[string][]string)
+		for table, rows := range tables {
+			for _, row := range rows {
+				if !withNestedIds && isNestedTableRow(row) {
+					row = maps.Clone(row)
+					delete(row, "_id")
+				}
+				data, err := json.Marshal(row)
+				if err != nil {
+					t.Fatalf("Error while encoding row: %v", err)
+				}
+				result[table] = append(result[table], string(data))
+			}
+			slices.Sort(result[table])
+		}
+		return result
+	}
+
+	expRows, gotRows := normalize(exp), normalize(got)
+	if !reflect.DeepEqual(expRows, gotRows) {
+		t.Errorf("Expected %s rows\n%s\nbut got\n%s", name, formatFixtureRows(expRows), formatFixtureRows(gotRows))
+	}
+}
+
+// rows of the nested object tables have a reference to the parent _id
+func isNestedTableRow(row map[string]interface{}) bool {
+	for col := range row {
+		if strings.HasSuffix(col, "__id") {
+			return true
+		}
+	}
+	return false
+}
+
+func formatFixtureRows(tables map[string][]string) string {
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	for _, table := range names {
+		b.WriteString(table + ":\n\t" + strings.Join(tables[table], "\n\t") + "\n")
+	}
+	return b.String()
+}
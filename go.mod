@@ -2,9 +2,30 @@ module github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser
 
 go 1.22.1
 
-require github.com/pganalyze/pg_query_go/v5 v5.1.0
+require (
+	github.com/mattn/go-sqlite3 v1.14.52
+	github.com/parquet-go/parquet-go v0.24.0
+	github.com/pganalyze/pg_query_go/v5 v5.1.0
+	go.mongodb.org/mongo-driver v1.16.1
+)
 
 require (
-	go.mongodb.org/mongo-driver v1.16.1 // indirect
-	google.golang.org/protobuf v1.31.0 // indirect
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/golang/snappy v0.0.4 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/mattn/go-runewidth v0.0.15 // indirect
+	github.com/montanaflynn/stats v0.7.1 // indirect
+	github.com/olekukonko/tablewriter v0.0.5 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
+	github.com/xdg-go/scram v1.1.2 // indirect
+	github.com/xdg-go/stringprep v1.0.4 // indirect
+	github.com/youmark/pkcs8 v0.0.0-20181117223130-1be2e3e5546d // indirect
+	golang.org/x/crypto v0.22.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
 )
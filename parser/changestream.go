@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// change stream events are recognised by this key, raw oplogs don't have it
+var changeEventKey = "operationType"
+
+func isChangeEvent(obj map[string]interface{}) bool {
+	_, ok := obj[changeEventKey]
+	return ok
+}
+
+// returns the _id of the change event, which is its resume token, nil if it has none
+func getResumeToken(event map[string]interface{}) json.RawMessage {
+	id, ok := event[idKey]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(id)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// converts a change stream event to the equivalent oplogs, so that the same
+// statements are generated for both. replace is converted to a delete followed by
+// an insert of the full document, as the fields missing from it have to go away.
+func changeEventToOplogs(event map[string]interface{}) ([]map[string]interface{}, error) {
+	opType, ok := event[changeEventKey].(string)
+	if !ok {
+		return nil, fmt.Errorf("error: unsupported change event type %q", event[changeEventKey])
+	}
+
+	nsMap, ok := event["ns"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error: ns key not found in the change event: failed to set the table name")
+	}
+	dbName, dbOk := nsMap["db"].(string)
+	collName, collOk := nsMap["coll"].(string)
+	if !dbOk || !collOk {
+		return nil, fmt.Errorf("error: ns.db or ns.coll not found in the change event: failed to set the table name")
+	}
+	ns := dbName + "." + collName
+
+	documentKey, hasDocumentKey := event["documentKey"].(map[string]interface{})
+	fullDocument, hasFullDocument := event["fullDocument"].(map[string]interface{})
+
+	var oplogs []map[string]interface{}
+	switch opType {
+	case "insert":
+		if !hasFullDocument {
+			return nil, fmt.Errorf("error: fullDocument not found in the insert change event")
+		}
+		oplogs = []map[string]interface{}{{"op": "i", "ns": ns, "o": fullDocument}}
+	case "update":
+		if !hasDocumentKey {
+			return nil, fmt.Errorf("error: documentKey not found in the update change event")
+		}
+		diff, err := getUpdateDiff(event["updateDescription"])
+		if err != nil {
+			return nil, err
+		}
+		oplogs = []map[string]interface{}{{"op": "u", "ns": ns, "o": map[string]interface{}{"$v": 2.0, "diff": diff}, "o2": documentKey}}
+	case "replace":
+		if !hasDocumentKey || !hasFullDocument {
+			return nil, fmt.Errorf("error: documentKey or fullDocument not found in the replace change event")
+		}
+		oplogs = []map[string]interface{}{
+			{"op": "d", "ns": ns, "o": documentKey},
+			{"op": "i", "ns": ns, "o": fullDocument},
+		}
+	case "delete":
+		if !hasDocumentKey {
+			return nil, fmt.Errorf("error: documentKey not found in the delete change event")
+		}
+		oplogs = []map[string]interface{}{{"op": "d", "ns": ns, "o": documentKey}}
+	default:
+		return nil, fmt.Errorf("error: unsupported change event type %q", opType)
+	}
+
+	// clusterTime of the event is the ts of the oplog behind it
+	if ts, ok := event["clusterTime"]; ok {
+		for _, oplog := range oplogs {
+			oplog["ts"] = ts
+		}
+	}
+	return oplogs, nil
+}
+
+// converts the updateDescription of the change event to the diff of the oplog
+func getUpdateDiff(data interface{}) (map[string]interface{}, error) {
+	updateDescription, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error: updateDescription not found in the update change event")
+	}
+
+	diff := make(map[string]interface{})
+	if updatedFields, ok := updateDescription["updatedFields"].(map[string]interface{}); ok && len(updatedFields) > 0 {
+		for key := range updatedFields {
+			if strings.Contains(key, ".") {
+				return nil, fmt.Errorf("error: unsupported update of nested field %q", key)
+			}
+		}
+		diff["u"] = updatedFields
+	}
+
+	if removedFields, ok := updateDescription["removedFields"].([]interface{}); ok && len(removedFields) > 0 {
+		unsetFields := make(map[string]interface{})
+		for _, field := range removedFields {
+			key, ok := field.(string)
+			if !ok || strings.Contains(key, ".") {
+				return nil, fmt.Errorf("error: unsupported removed field %v", field)
+			}
+			unsetFields[key] = false
+		}
+		diff["d"] = unsetFields
+	}
+	return diff, nil
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestChangeStreamEvents(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		exp string
+		expErr bool
+	}{
+		{
+			name: "insert event",
+			input: `{
+				"_id": {"_data": "8263"},
+				"operationType": "insert",
+				"clusterTime": {"$timestamp": {"t": 1700000000, "i": 1}},
+				"ns": {"db": "test", "coll": "student"},
+				"documentKey": {"_id": "635b79e231d82a8ab1de863b"},
+				"fullDocument": {
+					"_id": "635b79e231d82a8ab1de863b",
+					"name": "Selena Miller",
+					"roll_no": 51,
+					"phone": {"work": "8130097989"}
+				}
+			}`,
+			exp: `
+				CREATE SCHEMA test;
+				CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255), roll_no FLOAT);
+				INSERT INTO test.student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 51);
+				CREATE TABLE test.student_phone (_id VARCHAR(255) PRIMARY KEY, student__id VARCHAR(255), work VARCHAR(255));
+				INSERT INTO test.student_phone (_id, student__id, work) VALUES ('14798c213f273a7ca2cf5174', '635b79e231d82a8ab1de863b', '8130097989');
+			`,
+		},
+		{
+			name: "update event with updated fields",
+			input: `{
+				"operationType": "update",
+				"ns": {"db": "test", "coll": "student"},
+				"documentKey": {"_id": "635b79e231d82a8ab1de863b"},
+				"updateDescription": {"updatedFields": {"is_graduated": true}, "removedFields": [], "truncatedArrays": []}
+			}`,
+			exp: "UPDATE test.student SET is_graduated = true WHERE _id = '635b79e231d82a8ab1de863b';",
+		},
+		{
+			name: "update event with removed fields",
+			input: `{
+				"operationType": "update",
+				"ns": {"db": "test", "coll": "student"},
+				"documentKey": {"_id": "635b79e231d82a8ab1de863b"},
+				"updateDescription": {"updatedFields": {}, "removedFields": ["roll_no"]}
+			}`,
+			exp: "UPDATE test.student SET roll_no = NULL WHERE _id = '635b79e231d82a8ab1de863b';",
+		},
+		{
+			name: "replace event",
+			input: `{
+				"operationType": "replace",
+				"ns": {"db": "test", "coll": "student"},
+				"documentKey": {"_id": "635b79e231d82a8ab1de863b"},
+				"fullDocument": {"_id": "635b79e231d82a8ab1de863b", "name": "George Smith"}
+			}`,
+			exp: `
+				DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
+				CREATE SCHEMA test;
+				CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+				INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'George Smith');
+			`,
+		},
+		{
+			name: "delete event",
+			input: `{
+				"operationType": "delete",
+				"ns": {"db": "test", "coll": "student"},
+				"documentKey": {"_id": "635b79e231d82a8ab1de863b"}
+			}`,
+			exp: "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';",
+		},
+		{
+			name: "mixed with oplogs",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}},
+				{"operationType": "delete", "ns": {"db": "test", "coll": "student"}, "documentKey": {"_id": "635b79e231d82a8ab1de863b"}}
+			]`,
+			exp: `
+				CREATE SCHEMA test;
+				CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+				INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');
+				DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
+			`,
+		},
+		{
+			name: "unsupported event",
+			input: `{"operationType": "drop", "ns": {"db": "test", "coll": "student"}}`,
+			expErr: true,
+		},
+		{
+			name: "event without ns",
+			input: `{"operationType": "delete", "documentKey": {"_id": "1"}}`,
+			expErr: true,
+		},
+		{
+			name: "insert event without full document",
+			input: `{"operationType": "insert", "ns": {"db": "test", "coll": "student"}, "documentKey": {"_id": "1"}}`,
+			expErr: true,
+		},
+		{
+			name: "update event of nested field",
+			input: `{
+				"operationType": "update",
+				"ns": {"db": "test", "coll": "student"},
+				"documentKey": {"_id": "1"},
+				"updateDescription": {"updatedFields": {"phone.work": "8130097989"}}
+			}`,
+			expErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMockMongoOplogParser()
+
+			got, err := m.GetEquivalentSQL(tc.input)
+			if tc.expErr {
+				if err == nil {
+					t.Errorf("Expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := compareSqlStatement(t, tc.exp, got)
+			if err != nil {
+				t.Fatalf("Error while comparing SQL statements: %v", err)
+			}
+
+			if !result {
+				t.Errorf("Expected %q but got %q", tc.exp, got)
+			}
+		})
+	}
+}
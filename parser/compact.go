@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"maps"
+	"slices"
+)
+
+// WithCompaction makes CompactStatements fold the statements for the same row into their net effect
+func WithCompaction() Option {
+	return func(m *MongoOplogParser) {
+		m.compact = true
+	}
+}
+
+// row of a table, identified by its _id
+type rowKey struct {
+	dbName string
+	tableName string
+	id interface{}
+}
+
+// CompactStatements folds the statements for the same row, i.e. the same ns and _id, into
+// their net effect, if enabled by WithCompaction. Insert followed by updates becomes a single
+// insert, insert followed by a delete goes away, or becomes the delete in idempotent mode,
+// updates are merged into one and updates followed by a delete become the delete. The net
+// statement takes the place of the last one folded into it, so that the columns it uses have
+// been added by then. Only the statements with a condition on _id alone are folded, any other
+// update or delete of a table may touch any of its rows, so statements are not folded across it.
+func(m *MongoOplogParser) CompactStatements(stmts []Statement) []Statement {
+	if !m.compact {
+		return stmts
+	}
+
+	result := make([]*Statement, 0, len(stmts))
+	pending := make(map[rowKey]int)		// index of the net statement for the row in result
+	for i := range stmts {
+		stmt := &stmts[i]
+		key, ok := getRowKey(stmt)
+		if !ok {
+			if stmt.Kind == Update || stmt.Kind == Delete {
+				for k := range pending {
+					if k.dbName == stmt.DBName && k.tableName == stmt.TableName {
+						delete(pending, k)
+					}
+				}
+			}
+			result = append(result, stmt)
+			continue
+		}
+
+		if j, ok := pending[key]; ok {
+			if net, folded := m.fold(result[j], stmt); folded {
+				result[j] = nil
+				if net == nil {
+					delete(pending, key)
+					continue
+				}
+				stmt = net
+			}
+		}
+		result = append(result, stmt)
+		pending[key] = len(result) - 1
+	}
+
+	var compacted []Statement
+	for _, stmt := range result {
+		if stmt != nil {
+			compacted = append(compacted, *stmt)
+		}
+	}
+	return compacted
+}
+
+// returns the row the statement is about, ok is false for the ones which can't be folded
+func getRowKey(stmt *Statement) (rowKey, bool) {
+	switch stmt.Kind {
+	case Insert:
+		if len(stmt.Rows) != 1 {
+			return rowKey{}, false
+		}
+		if i := slices.Index(stmt.Columns, idKey); i >= 0 {
+			return rowKey{stmt.DBName, stmt.TableName, stmt.Rows[0][i]}, true
+		}
+	case Update, Delete:
+		if id, ok := stmt.Where[idKey]; ok && len(stmt.Where) == 1 {
+			return rowKey{stmt.DBName, stmt.TableName, id}, true
+		}
+	}
+	return rowKey{}, false
+}
+
+// folds the statement into the previous one for the same row, net is nil if nothing is left
+// folded is false if the statements have to be left as they are
+func(m *MongoOplogParser) fold(prev, stmt *Statement) (net *Statement, folded bool) {
+	s := &MongoOplog{dbName: stmt.DBName, idempotent: m.idempotent, dialect: m.dialect}
+	switch {
+	case prev.Kind == Insert && stmt.Kind == Update:
+		return s.applyUpdate(prev, stmt), true
+	case prev.Kind == Insert && stmt.Kind == Delete:
+		// row may already be there while replaying, so it is still deleted in idempotent mode
+		if m.idempotent {
+			return stmt, true
+		}
+		return nil, true
+	case prev.Kind == Update && stmt.Kind == Update:
+		return s.mergeUpdates(prev, stmt), true
+	case prev.Kind == Update && stmt.Kind == Delete:
+		return stmt, true
+	default:
+		// i.e. delete followed by an insert, which can't be told apart from an upsert
+		return nil, false
+	}
+}
+
+// returns the insert of the row as it is after the update
+func(s *MongoOplog) applyUpdate(insert, update *Statement) *Statement {
+	vals := make(map[string]interface{}, len(insert.Columns))
+	types := make(map[string]string, len(insert.Columns))
+	for i, col := range insert.Columns {
+		vals[col] = insert.Rows[0][i]
+		types[col] = insert.Types[i]
+	}
+
+	// columns set to null are left out, same as for the insert, but are kept as null in
+	// idempotent mode, as the upsert would otherwise leave them as they are in a row already there
+	clearColumn := func(col string) {
+		if s.idempotent {
+			vals[col] = nil
+		} else {
+			delete(vals, col)
+		}
+	}
+	for col, val := range update.Set {
+		if col == idKey {
+			continue
+		}
+		if val == nil {
+			clearColumn(col)
+			continue
+		}
+		vals[col] = val
+		types[col] = GetColumnType(val)
+	}
+	for _, col := range update.Unset {
+		if col != idKey {
+			clearColumn(col)
+		}
+	}
+
+	net := &Statement{Kind: Insert, DBName: insert.DBName, TableName: insert.TableName, Ts: update.Ts, HasTs: update.HasTs}
+	row := make([]interface{}, 0, len(vals))
+	for _, col := range GetSortedKeys(vals) {
+		net.Columns = append(net.Columns, col)
+		net.Types = append(net.Types, types[col])
+		row = append(row, vals[col])
+	}
+	net.Rows = [][]interface{}{row}
+	net.SQL = s.getInsertStatement(net.TableName, net.Columns, net.Rows)
+	return net
+}
+
+// returns a single update with the effect of both the updates
+func(s *MongoOplog) mergeUpdates(prev, update *Statement) *Statement {
+	setMap := maps.Clone(prev.Set)
+	if setMap == nil {
+		setMap = make(map[string]interface{})
+	}
+	unset := make(map[string]bool)
+	for _, col := range prev.Unset {
+		unset[col] = true
+	}
+
+	for col, val := range update.Set {
+		setMap[col] = val
+		delete(unset, col)
+	}
+	for _, col := range update.Unset {
+		delete(setMap, col)
+		unset[col] = true
+	}
+
+	net := &Statement{
+		Kind: Update,
+		DBName: update.DBName,
+		TableName: update.TableName,
+		Set: setMap,
+		Unset: GetSortedKeys(unset),
+		Where: update.Where,
+		Ts: update.Ts,
+		HasTs: update.HasTs,
+	}
+	net.SQL = s.getUpdateStatement(net.TableName, net.Set, net.Unset, net.Where)
+	return net
+}
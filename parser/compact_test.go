@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompactStatements(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		idempotent bool
+		exp []string
+	}{
+		{
+			name: "insert and updates into one insert",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51, "phone": "+91-81254966457"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 52}}}, "o2": {"_id": "1"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"is_graduated": true}, "d": {"phone": false}}}, "o2": {"_id": "1"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena Smith"}}}, "o2": {"_id": "1"}}
+			]`,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), phone  VARCHAR(255), roll_no  FLOAT);",
+				"ALTER TABLE test_student ADD COLUMN is_graduated  BOOLEAN;",
+				"INSERT INTO test_student (_id, is_graduated, name, roll_no) VALUES ('1', true, 'Selena Smith', 52);",
+			},
+		},
+		{
+			name: "insert, updates and delete into nothing",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena Smith"}}}, "o2": {"_id": "1"}},
+				{"op": "d", "ns": "test.student", "o": {"_id": "1"}}
+			]`,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('2', 'George Smith');",
+			},
+		},
+		{
+			name: "insert and delete into delete in idempotent mode",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "d", "ns": "test.student", "o": {"_id": "1"}}
+			]`,
+			idempotent: true,
+			exp: []string{
+				"CREATE TABLE IF NOT EXISTS test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"DELETE FROM test_student WHERE _id = '1';",
+			},
+		},
+		{
+			name: "insert and unset into insert with null in idempotent mode",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "phone": "+91-81254966457"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"d": {"phone": false}}}, "o2": {"_id": "1"}}
+			]`,
+			idempotent: true,
+			exp: []string{
+				"CREATE TABLE IF NOT EXISTS test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), phone  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name, phone) VALUES ('1', 'Selena Miller', NULL) ON CONFLICT (_id) DO UPDATE SET name = EXCLUDED.name, phone = EXCLUDED.phone;",
+			},
+		},
+		{
+			name: "updates of an existing row merged",
+			input: `[
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 52}, "d": {"phone": false}}}, "o2": {"_id": "1"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"phone": "+91-81254966457"}, "d": {"roll_no": false}}}, "o2": {"_id": "1"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena Smith"}}}, "o2": {"_id": "2"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"is_graduated": true}}}, "o2": {"_id": "2"}},
+				{"op": "d", "ns": "test.student", "o": {"_id": "2"}}
+			]`,
+			exp: []string{
+				"UPDATE test_student SET phone = '+91-81254966457', roll_no = NULL WHERE _id = '1';",
+				"DELETE FROM test_student WHERE _id = '2';",
+			},
+		},
+		{
+			name: "net insert placed after the new column",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith", "roll_no": 51}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 52}}}, "o2": {"_id": "1"}}
+			]`,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"ALTER TABLE test_student ADD COLUMN roll_no  FLOAT;",
+				"INSERT INTO test_student (_id, name, roll_no) VALUES ('2', 'George Smith', 51);",
+				"INSERT INTO test_student (_id, name, roll_no) VALUES ('1', 'Selena Miller', 52);",
+			},
+		},
+		{
+			name: "not folded across a condition on other columns",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "d", "ns": "test.student", "o": {"name": "Selena Miller"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena Smith"}}}, "o2": {"_id": "1"}}
+			]`,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Miller');",
+				"DELETE FROM test_student WHERE name = 'Selena Miller';",
+				"UPDATE test_student SET name = 'Selena Smith' WHERE _id = '1';",
+			},
+		},
+		{
+			name: "delete and insert left as they are",
+			input: `[
+				{"op": "d", "ns": "test.student", "o": {"_id": "1"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena Smith"}}}, "o2": {"_id": "1"}}
+			]`,
+			exp: []string{
+				"DELETE FROM test_student WHERE _id = '1';",
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Smith');",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMockMongoOplogParser()
+			WithDialect(SQLite)(m)
+			WithCompaction()(m)
+			if tc.idempotent {
+				WithIdempotent()(m)
+			}
+
+			stmts, err := m.ResolveStatements(m.Prepare(tc.input))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := GetSQL(m.CompactStatements(stmts))
+			if !slices.Equal(tc.exp, got) {
+				t.Errorf("Expected %q but got %q", tc.exp, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect decides the flavour of sql generated by the parser
+type Dialect int
+
+const (
+	// Postgres maps mongo databases to schemas, i.e. test.student
+	Postgres Dialect = iota
+	// SQLite has no schemas, so database is prefixed to the table name, i.e. test_student
+	SQLite
+)
+
+// WithDialect makes the parser generate sql for the given dialect, default is Postgres
+func WithDialect(dialect Dialect) Option {
+	return func(m *MongoOplogParser) {
+		m.dialect = dialect
+	}
+}
+
+// ParseDialect returns the dialect for the given name, i.e. postgres or sqlite
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "postgres", "postgresql", "pg":
+		return Postgres, nil
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	default:
+		return Postgres, fmt.Errorf("error: unsupported dialect %q", name)
+	}
+}
+
+func (d Dialect) String() string {
+	switch d {
+	case Postgres:
+		return "postgres"
+	case SQLite:
+		return "sqlite"
+	default:
+		return fmt.Sprintf("Dialect(%d)", int(d))
+	}
+}
+
+// returns the table name qualified with the database name as per the dialect
+func(s *MongoOplog) getQualifiedTableName(tableName string) string {
+	if s.dialect == SQLite {
+		return s.dbName + "_" + tableName
+	}
+	return s.dbName + "." + tableName
+}
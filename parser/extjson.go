@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"strconv"
+	"time"
+)
+
+// layout used by mongodb for the dates in extended json
+var extendedJSONDateLayout = "2006-01-02T15:04:05.000Z"
+
+// replaces the extended json wrappers, as written by mongoexport or the live oplog
+// source, with the plain values they stand for, e.g. {"$oid": "..."} with the hex
+// string and {"$numberLong": "..."} with the number. Wrappers which have no plain
+// equivalent, like $binary, are left as they are.
+func normalizeExtendedJSON(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeExtendedJSON(item)
+		}
+		return v
+	case map[string]interface{}:
+		if plain, ok := getExtendedJSONValue(v); ok {
+			return plain
+		}
+		for key, item := range v {
+			v[key] = normalizeExtendedJSON(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// returns the plain value of the extended json wrapper, ok is false if m isn't one
+func getExtendedJSONValue(m map[string]interface{}) (interface{}, bool) {
+	if len(m) != 1 {
+		return nil, false
+	}
+
+	for key, val := range m {
+		switch key {
+		case "$oid", "$symbol":
+			s, ok := val.(string)
+			return s, ok
+		case "$numberInt", "$numberLong":
+			// integers are kept as int64, as float64 can't hold the ones above 2^53
+			s, ok := val.(string)
+			if !ok {
+				return nil, false
+			}
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			return n, true
+		case "$numberDouble", "$numberDecimal":
+			s, ok := val.(string)
+			if !ok {
+				return nil, false
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, false
+			}
+			return f, true
+		case "$date":
+			return getExtendedJSONDate(val)
+		}
+	}
+	return nil, false
+}
+
+// dates are iso strings in relaxed mode and milliseconds since epoch otherwise
+// both are written in the same layout, so that the values don't depend on the mode
+func getExtendedJSONDate(val interface{}) (interface{}, bool) {
+	var ms int64
+	switch v := val.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return v, true
+		}
+		ms = t.UnixMilli()
+	case float64:
+		ms = int64(v)
+	case map[string]interface{}:
+		s, ok := v["$numberLong"].(string)
+		if !ok || len(v) != 1 {
+			return nil, false
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		ms = n
+	default:
+		return nil, false
+	}
+	return time.UnixMilli(ms).UTC().Format(extendedJSONDateLayout), true
+}
@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+type fieldAction int
+
+const (
+	dropField fieldAction = iota
+	renameField
+	maskField
+)
+
+// FieldRules drop, rename or mask the top level fields of the documents before the sql
+// is generated, uniformly for the insert values, the update set clauses, the conditions of
+// the updates and deletes, and the schema. A condition on a dropped field is an error, as
+// leaving it out would match more rows.
+// Rules are given per namespace, as an exact name or a glob like the NamespaceFilter,
+// and matched against the original namespace. First matching rule for a field is applied.
+type FieldRules struct {
+	rules []fieldRule
+}
+
+type fieldRule struct {
+	ns namespaceRule
+	field string
+	action fieldAction
+	newName string
+}
+
+func NewFieldRules() *FieldRules {
+	return &FieldRules{}
+}
+
+// Drop leaves out the field, i.e. passwords which are not to be replicated
+func(r *FieldRules) Drop(ns, field string) error {
+	return r.add(ns, field, dropField, "")
+}
+
+// Rename changes the name of the column for the field, i.e. date_of_birth to dob
+func(r *FieldRules) Rename(ns, field, newName string) error {
+	if newName == "" || newName == idKey {
+		return fmt.Errorf("error: invalid new name %q for field %q", newName, field)
+	}
+	return r.add(ns, field, renameField, newName)
+}
+
+// Mask replaces the value of the field with its SHA-256 hash, i.e. for emails
+func(r *FieldRules) Mask(ns, field string) error {
+	return r.add(ns, field, maskField, "")
+}
+
+func(r *FieldRules) add(ns, field string, action fieldAction, newName string) error {
+	if field == "" || field == idKey {
+		return fmt.Errorf("error: invalid field %q: rules can't be applied to %s", field, idKey)
+	}
+	nsRule, err := newNamespaceRule(ns)
+	if err != nil {
+		return fmt.Errorf("error: invalid namespace %q for field %q: %v", ns, field, err)
+	}
+
+	r.rules = append(r.rules, fieldRule{ns: nsRule, field: field, action: action, newName: newName})
+	return nil
+}
+
+// returns the rule for the field of the namespace, nil if there is none
+func(r *FieldRules) getRule(ns, field string) *fieldRule {
+	for i := range r.rules {
+		if r.rules[i].field == field && r.rules[i].ns.match(ns) {
+			return &r.rules[i]
+		}
+	}
+	return nil
+}
+
+// returns the fields of the document after applying the rules for the namespace
+func(r *FieldRules) apply(ns string, fields map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(fields))
+	for _, key := range GetSortedKeys(fields) {
+		val := fields[key]
+		newKey := key
+
+		if rule := r.getRule(ns, key); rule != nil {
+			switch rule.action {
+			case dropField:
+				continue
+			case renameField:
+				newKey = rule.newName
+			case maskField:
+				masked, err := maskValue(key, val)
+				if err != nil {
+					return nil, err
+				}
+				val = masked
+			}
+		}
+
+		// renamed field can't be merged with another one of the same name
+		if _, ok := result[newKey]; ok {
+			return nil, fmt.Errorf("error: field %q is renamed to %q which already exists", key, newKey)
+		}
+		result[newKey] = val
+	}
+	return result, nil
+}
+
+// returns the condition after applying the rules for the namespace, so that it refers to
+// the columns and values the rows were written with
+func(r *FieldRules) applyToCondition(ns string, fields map[string]interface{}) (map[string]interface{}, error) {
+	for _, key := range GetSortedKeys(fields) {
+		if rule := r.getRule(ns, key); rule != nil && rule.action == dropField {
+			return nil, fmt.Errorf("error: unsupported condition on dropped field %q", key)
+		}
+	}
+	return r.apply(ns, fields)
+}
+
+// applies the field rules to the document of the insert, the fields of the update, and
+// the conditions of the update and delete
+// skip is true if the update is left with no fields, as all of them were dropped
+func(s *MongoOplog) applyFieldRules(ns string, oplog map[string]interface{}) (skip bool, err error) {
+	doc, ok := oplog["o"].(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+
+	switch s.op {
+	case "i":
+		oplog["o"], err = s.fieldRules.apply(ns, doc)
+		return false, err
+	case "d":
+		oplog["o"], err = s.fieldRules.applyToCondition(ns, doc)
+		return false, err
+	}
+
+	if condition, ok := oplog["o2"].(map[string]interface{}); ok {
+		if oplog["o2"], err = s.fieldRules.applyToCondition(ns, condition); err != nil {
+			return false, err
+		}
+	}
+
+	diff, ok := doc["diff"].(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	before, after := 0, 0
+	for _, key := range []string{"u", "i", "d"} {
+		fields, ok := diff[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if diff[key], err = s.fieldRules.apply(ns, fields); err != nil {
+			return false, err
+		}
+		before += len(fields)
+		after += len(diff[key].(map[string]interface{}))
+	}
+	return before > 0 && after == 0, nil
+}
+
+// returns the hex encoded SHA-256 hash of the scalar value, null is left as it is
+func maskValue(key string, val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	if isNested(val) {
+		return nil, fmt.Errorf("error: unsupported nested value for %q while masking", key)
+	}
+
+	s, ok := val.(string)
+	if !ok {
+		s = fmt.Sprint(val)
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// WithFieldRules makes the parser apply the rules to the fields of the documents
+func WithFieldRules(r *FieldRules) Option {
+	return func(m *MongoOplogParser) {
+		m.fieldRules = r
+	}
+}
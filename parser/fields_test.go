@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMongoOplogParserFieldRules(t *testing.T) {
+	input := `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller", "date_of_birth": "2000-01-30", "email": "selena@example.com", "password": "secret", "address": [{"line1": "481 Harborsburgh", "zip": "89799"}]}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"email": "selena.miller@example.com"}, "d": {"password": false}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"password": "changed"}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"i": {"date_of_birth": "2000-01-31", "password": "added"}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}},
+		{"op": "i", "ns": "test.teacher", "o": {"_id": "14798c213f273a7ca2cf5174", "email": "jane@example.com", "password": "secret"}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena M. Miller"}}}, "o2": {"_id": "635b79e231d82a8ab1de863b", "date_of_birth": "2000-01-31"}},
+		{"op": "d", "ns": "test.teacher", "o": {"email": "jane@example.com"}}
+	]`
+	exp := []string{
+		"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, dob  VARCHAR(255), email  VARCHAR(255), name  VARCHAR(255));",
+		"INSERT INTO test_student (_id, dob, email, name) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', 'da7ccf6eebfcc0ffe77abff1abd5ff13a2002e74fb5720117a088e67ad63cd97', 'Selena Miller');",
+		"UPDATE test_student SET email = 'd1fe6df2a2b863a960d5f10210ada3468b93628009dcd772c79bcecb2902540d' WHERE _id = '635b79e231d82a8ab1de863b';",
+		"UPDATE test_student SET dob = '2000-01-31' WHERE _id = '635b79e231d82a8ab1de863b';",
+		"CREATE TABLE test_teacher (_id  VARCHAR(255) PRIMARY KEY, email  VARCHAR(255));",
+		"INSERT INTO test_teacher (_id, email) VALUES ('14798c213f273a7ca2cf5174', '8c87b489ce35cf2e2f39f80e282cb2e804932a56a213983eeeb428407d43b52d');",
+		"UPDATE test_student SET name = 'Selena M. Miller' WHERE _id = '635b79e231d82a8ab1de863b' AND dob = '2000-01-31';",
+		"DELETE FROM test_teacher WHERE email = '8c87b489ce35cf2e2f39f80e282cb2e804932a56a213983eeeb428407d43b52d';",
+	}
+
+	rules := NewFieldRules()
+	for _, err := range []error{
+		rules.Drop("test.*", "password"),
+		rules.Drop("test.student", "address"),
+		rules.Rename("test.student", "date_of_birth", "dob"),
+		rules.Mask("test.*", "email"),
+	} {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	m := NewMockMongoOplogParser()
+	WithDialect(SQLite)(m)
+	WithFieldRules(rules)(m)
+
+	got, err := m.GetEquivalentSQLStatements(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(exp, got) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+func TestFieldRulesInvalid(t *testing.T) {
+	rules := NewFieldRules()
+	if err := rules.Drop("test.student", "_id"); err == nil {
+		t.Errorf("Expected error for dropping _id but got nil")
+	}
+	if err := rules.Rename("test.student", "name", ""); err == nil {
+		t.Errorf("Expected error for renaming to empty name but got nil")
+	}
+	if err := rules.Mask("test.[", "email"); err == nil {
+		t.Errorf("Expected error for invalid namespace but got nil")
+	}
+
+	if err := rules.Rename("test.student", "date_of_birth", "dob"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	m := NewMockMongoOplogParser()
+	WithFieldRules(rules)(m)
+	_, err := m.GetEquivalentSQL(`{"op": "i", "ns": "test.student", "o": {"_id": "1", "date_of_birth": "2000-01-30", "dob": "2000-01-30"}}`)
+	if err == nil {
+		t.Errorf("Expected error for renaming to an existing field but got nil")
+	}
+
+	// leaving out the condition would delete more rows than the oplog did
+	if err := rules.Drop("test.student", "password"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = m.GetEquivalentSQL(`{"op": "d", "ns": "test.student", "o": {"_id": "1", "password": "secret"}}`)
+	if err == nil {
+		t.Errorf("Expected error for a condition on a dropped field but got nil")
+	}
+}
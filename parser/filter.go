@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// NamespaceFilter decides which namespaces are converted by the parser
+// Oplogs of the other namespaces are skipped without generating any statement.
+type NamespaceFilter struct {
+	includes []namespaceRule
+	excludes []namespaceRule
+}
+
+type namespaceRule struct {
+	glob string
+	re *regexp.Regexp
+}
+
+// NewNamespaceFilter returns a filter for the given rules, each of which is either
+//   - an exact namespace, i.e. test.student
+//   - a glob, i.e. test.* or test.student_?
+//   - a regex between slashes, i.e. /^test\.(student|teacher)$/
+//
+// Rules prefixed with ! exclude the matching namespaces, i.e. !test.audit_*.
+// A namespace is converted if it matches any of the include rules, or if there are
+// none, and it doesn't match any of the exclude rules.
+func NewNamespaceFilter(rules ...string) (*NamespaceFilter, error) {
+	f := &NamespaceFilter{}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		exclude := strings.HasPrefix(rule, "!")
+		pattern := strings.TrimPrefix(rule, "!")
+		if pattern == "" {
+			return nil, fmt.Errorf("error: empty namespace rule %q", rule)
+		}
+
+		r, err := newNamespaceRule(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error: invalid namespace rule %q: %v", rule, err)
+		}
+		if exclude {
+			f.excludes = append(f.excludes, r)
+		} else {
+			f.includes = append(f.includes, r)
+		}
+	}
+	return f, nil
+}
+
+func newNamespaceRule(pattern string) (namespaceRule, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return namespaceRule{}, err
+		}
+		return namespaceRule{re: re}, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return namespaceRule{}, err
+	}
+	return namespaceRule{glob: pattern}, nil
+}
+
+func(r namespaceRule) match(ns string) bool {
+	if r.re != nil {
+		return r.re.MatchString(ns)
+	}
+	ok, _ := path.Match(r.glob, ns)
+	return ok
+}
+
+// Match reports whether the oplogs of the namespace are to be converted
+func(f *NamespaceFilter) Match(ns string) bool {
+	for _, r := range f.excludes {
+		if r.match(ns) {
+			return false
+		}
+	}
+	if len(f.includes) == 0 {
+		return true
+	}
+	for _, r := range f.includes {
+		if r.match(ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithNamespaceFilter makes the parser skip the oplogs of the namespaces not matched by f,
+// i.e. to leave out admin.*, config.* and system collections
+func WithNamespaceFilter(f *NamespaceFilter) Option {
+	return func(m *MongoOplogParser) {
+		m.filter = f
+	}
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNamespaceFilter(t *testing.T) {
+	tt := []struct {
+		name string
+		rules []string
+		matched []string
+		skipped []string
+	}{
+		{
+			name: "no rules",
+			rules: nil,
+			matched: []string{"test.student", "admin.system.users"},
+		},
+		{
+			name: "exact name",
+			rules: []string{"test.student"},
+			matched: []string{"test.student"},
+			skipped: []string{"test.student_audit", "test.teacher"},
+		},
+		{
+			name: "glob with exclusion",
+			rules: []string{"test.*", "!test.audit_*"},
+			matched: []string{"test.student", "test.student.archive"},
+			skipped: []string{"test.audit_log", "school.student"},
+		},
+		{
+			name: "only exclusions",
+			rules: []string{"!admin.*", "!config.*", "!*.system.*"},
+			matched: []string{"test.student"},
+			skipped: []string{"admin.users", "config.system.sessions", "test.system.profile"},
+		},
+		{
+			name: "regex",
+			rules: []string{`/^test\.(student|teacher)$/`},
+			matched: []string{"test.student", "test.teacher"},
+			skipped: []string{"test.students", "school.student"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewNamespaceFilter(tc.rules...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for _, ns := range tc.matched {
+				if !f.Match(ns) {
+					t.Errorf("Expected %q to be matched", ns)
+				}
+			}
+			for _, ns := range tc.skipped {
+				if f.Match(ns) {
+					t.Errorf("Expected %q to be skipped", ns)
+				}
+			}
+		})
+	}
+}
+
+func TestNamespaceFilterInvalidRules(t *testing.T) {
+	for _, rule := range []string{"", "!", "test.[", "/(/"} {
+		if _, err := NewNamespaceFilter(rule); err == nil {
+			t.Errorf("Expected error for rule %q but got nil", rule)
+		}
+	}
+}
+
+func TestMongoOplogParserNamespaceFilter(t *testing.T) {
+	input := `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}},
+		{"op": "i", "ns": "test.audit_log", "o": {"_id": "14798c213f273a7ca2cf5174", "action": "login"}},
+		{"op": "i", "ns": "config.system.sessions", "o": {"_id": "14798c213f273a7ca2cf5174"}},
+		{"operationType": "delete", "ns": {"db": "test", "coll": "audit_log"}, "documentKey": {"_id": "14798c213f273a7ca2cf5174"}}
+	]`
+	exp := []string{
+		"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+		"INSERT INTO test_student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');",
+	}
+
+	f, err := NewNamespaceFilter("test.*", "!test.audit_*")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	m := NewMockMongoOplogParser()
+	WithDialect(SQLite)(m)
+	WithNamespaceFilter(f)(m)
+
+	got, err := m.GetEquivalentSQLStatements(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(exp, got) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+
+	// skipped oplogs generate nothing, not even an error
+	got, err = m.GetEquivalentSQLStatements(`{"op": "d", "ns": "admin.system.users", "o": {"_id": "1"}}`)
+	if err != nil || len(got) != 0 {
+		t.Errorf("Expected no statements but got %q, %v", got, err)
+	}
+}
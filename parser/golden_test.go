@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestGolden translates the oplogs of each testdata/<case>/input.json with each dialect,
+// and compares the sql, one statement per line, with testdata/<case>/expected.<dialect>.sql.
+// Oplogs which fail are written as -- error: lines, so that the cases can cover them too.
+// After an intended change of the output, the files are regenerated with
+//
+//	go test ./parser -run TestGolden -update
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob("../testdata/*/input.json")
+	if err != nil {
+		t.Fatalf("Error while listing golden cases: %v", err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("Expected golden cases in testdata")
+	}
+
+	for _, input := range inputs {
+		dir := filepath.Dir(input)
+		for _, dialect := range []Dialect{Postgres, SQLite} {
+			t.Run(filepath.Base(dir) + "/" + dialect.String(), func(t *testing.T) {
+				got := getGoldenSQL(t, input, dialect)
+				expFile := filepath.Join(dir, "expected." + dialect.String() + ".sql")
+
+				if *update {
+					if err := os.WriteFile(expFile, []byte(got), 0644); err != nil {
+						t.Fatalf("Error while updating golden file: %v", err)
+					}
+					return
+				}
+
+				exp, err := os.ReadFile(expFile)
+				if err != nil {
+					t.Fatalf("Error while reading golden file, run with -update to create it: %v", err)
+				}
+				if got != string(exp) {
+					t.Errorf("Expected %s\n%s\nbut got\n%s", expFile, exp, got)
+				}
+			})
+		}
+	}
+}
+
+// returns the sql for the oplogs in the file, one statement per line
+func getGoldenSQL(t *testing.T, input string, dialect Dialect) string {
+	t.Helper()
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatalf("Error while reading input: %v", err)
+	}
+
+	// ids of the nested object rows are numbered, so that they are the same on each run
+	m := NewMockMongoOplogParser()
+	WithDialect(dialect)(m)
+	n := 0
+	m.genUuid = func() string {
+		n++
+		return fmt.Sprintf("%024x", n)
+	}
+
+	var b strings.Builder
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var oplog json.RawMessage
+		if err := decoder.Decode(&oplog); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("Error while decoding input: %v", err)
+		}
+
+		stmts, err := m.GetEquivalentSQLStatements(string(oplog))
+		if err != nil {
+			b.WriteString("-- " + err.Error() + "\n")
+			continue
+		}
+		for _, stmt := range stmts {
+			b.WriteString(stmt + "\n")
+		}
+	}
+	return b.String()
+}
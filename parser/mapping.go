@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamespaceMapper maps the mongo namespaces to the schema and table names used in the sql,
+// for both the collection tables and the tables of their nested objects
+type NamespaceMapper struct {
+	// DBPrefix is prepended to the database names after renaming, i.e. mongo_ for mongo_school
+	DBPrefix string
+	// LowerCase makes the database and table names lowercase
+	LowerCase bool
+
+	namespaces map[string]string	// <db>.<collection> to <db>.<table>
+	dbs map[string]string			// <db> to <db>
+}
+
+// NewNamespaceMapper returns a mapper for the rules of the form <from>=<to>, either for a
+// namespace, i.e. test.student=school.students, or for a database, i.e. test=school.
+// Namespace rules take precedence over the database rules.
+func NewNamespaceMapper(rules ...string) (*NamespaceMapper, error) {
+	nm := &NamespaceMapper{
+		namespaces: make(map[string]string),
+		dbs: make(map[string]string),
+	}
+	for _, rule := range rules {
+		from, to, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("error: invalid namespace mapping %q: expected <from>=<to>", rule)
+		}
+
+		if strings.Contains(from, ".") {
+			if _, _, err := parseNamespace(from); err != nil {
+				return nil, fmt.Errorf("error: invalid namespace mapping %q: %v", rule, err)
+			}
+			if _, _, err := parseNamespace(to); err != nil {
+				return nil, fmt.Errorf("error: invalid namespace mapping %q: %v", rule, err)
+			}
+			nm.namespaces[from] = to
+		} else {
+			if strings.Contains(to, ".") {
+				return nil, fmt.Errorf("error: invalid namespace mapping %q: database can't be mapped to a namespace", rule)
+			}
+			nm.dbs[from] = to
+		}
+	}
+	return nm, nil
+}
+
+// Map returns the schema and table names for the collection
+func(nm *NamespaceMapper) Map(dbName, collName string) (string, string) {
+	if to, ok := nm.namespaces[dbName+"."+collName]; ok {
+		dbName, collName, _ = parseNamespace(to)
+	} else if to, ok := nm.dbs[dbName]; ok {
+		dbName = to
+	}
+
+	dbName = nm.DBPrefix + dbName
+	if nm.LowerCase {
+		dbName, collName = strings.ToLower(dbName), strings.ToLower(collName)
+	}
+	return dbName, collName
+}
+
+// returns the name of the table for the nested object of the parent table
+func(nm *NamespaceMapper) mapNestedTable(parentTable, key string) string {
+	tableName := parentTable + "_" + sanitizeIdentifier(key)
+	if nm != nil && nm.LowerCase {
+		tableName = strings.ToLower(tableName)
+	}
+	return tableName
+}
+
+// WithNamespaceMapper makes the parser use the schema and table names given by nm
+// instead of the database and collection names, i.e. to follow warehouse naming conventions
+func WithNamespaceMapper(nm *NamespaceMapper) Option {
+	return func(m *MongoOplogParser) {
+		m.mapper = nm
+	}
+}
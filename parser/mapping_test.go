@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNamespaceMapper(t *testing.T) {
+	nm, err := NewNamespaceMapper("test.student=school.students", "test=archive", "Sales=crm")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	nm.DBPrefix = "mongo_"
+	nm.LowerCase = true
+
+	tt := []struct {
+		db string
+		coll string
+		expDb string
+		expTable string
+	}{
+		{db: "test", coll: "student", expDb: "mongo_school", expTable: "students"},
+		{db: "test", coll: "teacher", expDb: "mongo_archive", expTable: "teacher"},
+		{db: "Sales", coll: "Orders", expDb: "mongo_crm", expTable: "orders"},
+		{db: "Other", coll: "Items", expDb: "mongo_other", expTable: "items"},
+	}
+
+	for _, tc := range tt {
+		gotDb, gotTable := nm.Map(tc.db, tc.coll)
+		if gotDb != tc.expDb || gotTable != tc.expTable {
+			t.Errorf("Expected %s.%s to be mapped to %s.%s but got %s.%s", tc.db, tc.coll, tc.expDb, tc.expTable, gotDb, gotTable)
+		}
+	}
+}
+
+func TestNamespaceMapperInvalidRules(t *testing.T) {
+	for _, rule := range []string{"test.student", "=school", "test.student=school", "test=school.students", "test.=school.students"} {
+		if _, err := NewNamespaceMapper(rule); err == nil {
+			t.Errorf("Expected error for rule %q but got nil", rule)
+		}
+	}
+}
+
+func TestMongoOplogParserNamespaceMapper(t *testing.T) {
+	input := `[
+		{"op": "i", "ns": "test.Student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller", "Phone": {"work": "8130097989"}}},
+		{"op": "u", "ns": "test.Student", "o": {"$v": 2, "diff": {"u": {"name": "Selena M"}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}},
+		{"op": "d", "ns": "test.Student", "o": {"_id": "635b79e231d82a8ab1de863b"}}
+	]`
+	exp := []string{
+		"CREATE SCHEMA school;",
+		"CREATE TABLE school.students (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+		"INSERT INTO school.students (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');",
+		"CREATE TABLE school.students_phone (_id  VARCHAR(255) PRIMARY KEY, students__id  VARCHAR(255), work  VARCHAR(255));",
+		"INSERT INTO school.students_phone (_id, students__id, work) VALUES ('14798c213f273a7ca2cf5174', '635b79e231d82a8ab1de863b', '8130097989');",
+		"UPDATE school.students SET name = 'Selena M' WHERE _id = '635b79e231d82a8ab1de863b';",
+		"DELETE FROM school.students WHERE _id = '635b79e231d82a8ab1de863b';",
+	}
+
+	nm, err := NewNamespaceMapper("test.Student=school.Students")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	nm.LowerCase = true
+	m := NewMockMongoOplogParser()
+	WithNamespaceMapper(nm)(m)
+
+	got, err := m.GetEquivalentSQLStatements(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(exp, got) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
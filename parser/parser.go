@@ -1,413 +1,1013 @@
-package parser
-
-import (
-	"encoding/json"
-	"fmt"
-	"reflect"
-	"slices"
-	"strconv"
-	"strings"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
-
-var idKey = "_id"
-
-type MongoOplogParser struct {
-	cache map[string]map[string]string
-	genUuid func()string
-}
-
-type MongoOplog struct {
-	rawOplog string
-	op string
-	dbName string
-	tableName string
-	tableCols map[string]string			// holds the table columns schema
-	keys []string						// keys for insert operation
-	vals []string						// values for insert operation
-	setMap map[string]string			// key-val for update set operation
-	unsetMap map[string]string			// key-val for update unset operation
-	conditionMap map[string]string		// key-val for condition clause
-	query []string						// holds the final sql query
-	isSchemaCreated bool
-	isSchemaParsed bool
-	genUuid func()string
-	cache *map[string]map[string]string
-}
-
-func NewMongoOplogParser() *MongoOplogParser {
-	return &MongoOplogParser{
-		genUuid: func() string {
-			return primitive.NewObjectID().Hex()
-		},
-	}
-}
-
-func(m *MongoOplogParser) GetEquivalentSQL(rawOplog string) (string, error) {
-	s := &MongoOplog{
-		rawOplog: rawOplog,
-		cache: &m.cache,
-		genUuid: m.genUuid,
-	}
-
-	// unmarshalling the raw oplog
-	var obj interface{}
-	err := json.Unmarshal([]byte(s.rawOplog), &obj)
-	if err != nil {
-		return "", err
-	}
-
-	// to handle both type, slice of json and single json
-	var result []map[string]interface{}
-	switch reflect.TypeOf(obj).Kind() {
-	case reflect.Slice:
-		for _, item := range obj.([]interface{}) {
-            if obj, ok := item.(map[string]interface{}); ok {
-                result = append(result, obj)
-            }
-        }
-	case reflect.Map:
-		result = append(result, obj.(map[string]interface{}))
-	}
-
-	// parsing the raw oplog
-	for _, r := range result {
-		err = s.parse(r)
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		// if r has nested objects and has _id key, then proceed further
-		nestedMap, ok := r["o"].(map[string]interface{})
-		if !ok {
-			continue
-		}
-		if _, ok := nestedMap[idKey]; !ok {
-			continue
-		}
-
-		// preparing parent object key and value
-		parentObjVal := nestedMap[idKey].(string)
-		parentObjKey := s.tableName + "_" + idKey
-
-		// handling nested objects separetly for create table and insert statement
-		// to maintain consistency wrt testing
-		for key, val := range nestedMap {
-			if reflect.TypeOf(val).Kind() == reflect.Slice {
-				// for create table statement
-				createStmt, err := s.getForeignTableCreateStatement(val, key, parentObjKey, parentObjVal)
-				if err != nil {
-					fmt.Println(err)
-					break
-				}
-				s.query = append(s.query, createStmt)
-				
-				// for insert statement
-				insertStmt, err := s.getForeignTableInsertStatement(val, key, parentObjKey, parentObjVal)
-				if err != nil {
-					fmt.Println(err)
-					break
-				}
-				s.query = append(s.query, insertStmt...)
-			}
-		}
-
-		for key, val := range nestedMap {
-			if reflect.TypeOf(val).Kind() == reflect.Map {
-				// for create table statement
-				createStmt, err := s.getForeignTableCreateStatement(val, key, parentObjKey, parentObjVal)
-				if err != nil {
-					fmt.Println(err)
-					break
-				}
-				s.query = append(s.query, createStmt)
-
-				// for insert statement
-				insertStmt, err := s.getForeignTableInsertStatement(val, key, parentObjKey, parentObjVal)
-				if err != nil {
-					fmt.Println(err)
-					break
-				}
-				s.query = append(s.query, insertStmt...)
-			}
-		}
-	}
-
-	// maybe we need to reset a lot of fields here like setMap, unsetMap, conditionMap, etc
-	
-	return strings.Join(s.query, ""), nil
-}
-
-func(s *MongoOplog) parse(result map[string]interface{}) error {
-	if result["op"] == "i" || result["op"] == "u" || result["op"] == "d" {
-		s.op = result["op"].(string)
-	} else {
-		return fmt.Errorf("error: unsupported operation type %q", result["op"])
-	}
-
-    if result["ns"] != nil {
-        s.dbName = strings.Split(result["ns"].(string), ".")[0]
-		s.tableName = strings.Split(result["ns"].(string), ".")[1]
-    } else {
-		return fmt.Errorf("error: ns key not found in the oplog: failed to set the table name")
-	}
-
-    nestedMap, ok := result["o"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("error: o key not found in the oplog: failed to set keys and values")
-	}
-
-	// parsing the schema only once
-	// if any new key is found, alter table statement is added atm of handling insert operation
-	if !s.isSchemaParsed {
-		s.tableCols = make(map[string]string)
-		for key, val := range nestedMap {
-			// skip if value is map or slice
-			if reflect.TypeOf(val).Kind() == reflect.Map || reflect.TypeOf(val).Kind() == reflect.Slice {
-				continue
-			}
-
-			s.tableCols[key] = s.getTableColType(key, val)
-		}
-		s.isSchemaParsed = true
-	}
-
-   	if s.op == "i" {	// on insert operation
-		keys := make([]string, 0, len(nestedMap))
-		vals := make([]string, 0, len(nestedMap))
-		
-		// extracts the insert key and values
-		for key, val := range nestedMap {
-			// skip if value is map or slice
-			if reflect.TypeOf(val).Kind() == reflect.Map || reflect.TypeOf(val).Kind() == reflect.Slice {
-				continue
-			}
-
-			// if key is not in table schema, add alter table statement
-			if _, ok := s.tableCols[key]; !ok {
-				s.tableCols[key] = s.getTableColType(key, val)
-				s.query = append(s.query, s.getAlterTableStatement(key, s.tableCols[key]))
-			}
-
-			// adding key and value for query generation
-			keys = append(keys, key)
-			vals = append(vals, s.convertValueToString(val))
-		}
-
-		if !s.isSchemaCreated{
-			cols := s.getCreateTableValues(s.tableCols)
-
-			createSchema := fmt.Sprintf("CREATE SCHEMA %s;", s.dbName)
-			createTable := fmt.Sprintf("CREATE TABLE %s.%s (%s);", s.dbName, s.tableName, strings.Join(cols, ", "))
-
-			s.query = append(s.query, createSchema)
-			s.query = append(s.query, createTable)
-			s.isSchemaCreated = true
-		}
-
-		if len(keys) != len(vals) {
-			return fmt.Errorf("error: keys and values length mismatch while inserting")
-		}
-
-		insertQuery := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES (%s);", s.dbName, s.tableName, strings.Join(keys, ", "), strings.Join(vals, ", "))
-		s.query = append(s.query, insertQuery)
-	} else if s.op == "u" {		// on update operation
-		nestedMap, ok = result["o"].(map[string]interface{})["diff"].(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("error: diff key not found in the oplog: failed to set keys and values")
-		}
-
-		// extracts the update set key and value
-		setMap := make(map[string]string)
-		if nestedMap["u"] != nil {
-			// s.setMap = make(map[string]string)
-			for key, val := range nestedMap["u"].(map[string]interface{}) {
-				setMap[key] = s.convertValueToString(val)
-			}
-		}
-
-		// extracts the update unset key and value
-		unsetMap := make(map[string]string)
-		if nestedMap["d"] != nil {
-			// s.unsetMap = make(map[string]string)
-			for key, val := range nestedMap["d"].(map[string]interface{}) {
-				unsetMap[key] = s.convertValueToString(val)
-			}
-		}
-
-		// extracts the update condition
-		conditionMap := make(map[string]string)
-		if result["o2"] != nil {
-			// s.conditionMap = make(map[string]string)
-			for key, val := range result["o2"].(map[string]interface{}) {
-				conditionMap[key] = s.convertValueToString(val)
-			}
-		}
-
-		updateClause := s.getUpdateClause(setMap, unsetMap)
-		if updateClause == "" {
-			return fmt.Errorf("error: update clause not found while updating")
-		}
-
-		conditionClause := s.getConditionClause(conditionMap)
-		if conditionClause == "" {
-			return fmt.Errorf("error: condition clause not found while updating")
-		}
-
-		s.query = append(s.query, fmt.Sprintf("UPDATE %s.%s SET %s WHERE %s;", s.dbName, s.tableName, updateClause, conditionClause))
-	} else if s.op == "d" {		// on delete operation
-		conditionMap := make(map[string]string)
-		for key, val := range nestedMap {
-			conditionMap[key] = s.convertValueToString(val)
-		}
-
-		conditionClause := s.getConditionClause(conditionMap)
-		if conditionClause == "" {
-			return fmt.Errorf("error: condition clause not found while deleting")
-		}
-
-		s.query = append(s.query, fmt.Sprintf("DELETE FROM %s.%s WHERE %s;", s.dbName, s.tableName, conditionClause))
-	}
-	return nil
-}
-
-func(s *MongoOplog) getForeignTableCreateStatement(data interface{}, fTableName, parentObjKey, parentObjVal string) (string, error) {
-	var tableCols = make(map[string]string)
-
-	// saving two id columns first
-	tableCols[idKey] = s.getTableColType(idKey, s.convertValueToString(s.genUuid()))
-	tableCols[parentObjKey] = s.getTableColType(parentObjKey, s.convertValueToString(parentObjVal))
-
-	// if data is slice
-	if reflect.TypeOf(data).Kind() == reflect.Slice {
-		for key, val := range data.([]interface{})[0].(map[string]interface{}) {
-			tableCols[key] = s.getTableColType(key, val.(string))
-		}
-	}
-
-	// if data is map
-	if reflect.TypeOf(data).Kind() == reflect.Map {
-		for key, val := range data.(map[string]interface{}) {
-			tableCols[key] = s.getTableColType(key, val.(string))
-		}
-	}
-
-	cols := s.getCreateTableValues(tableCols)
-	slices.Sort(cols)	// sorting table columns to maintain consistency wrt testing
-
-	if len(cols) == 0 {
-		return "", fmt.Errorf("no columns to create %s table", fTableName)
-	}
-
-	createTable := fmt.Sprintf("CREATE TABLE %s.%s_%s (%s);", s.dbName, s.tableName, fTableName, strings.Join(cols, ", "))
-	return createTable, nil
-}
-
-func(s *MongoOplog) getForeignTableInsertStatement(data interface{}, fTableName, parentObjKey, parentObjVal string) ([]string, error) {
-	queries := []string{}
-	keysArr := []string{idKey, parentObjKey}
-	valsArr := []string{s.convertValueToString(s.genUuid()), s.convertValueToString(parentObjVal)}
-
-	// if data is slice
-	if reflect.TypeOf(data).Kind() == reflect.Slice {
-		for _, v := range data.([]interface{}) {
-			qs := s.craftForeignTableInsertStatement(v.(map[string]interface{}), fTableName, keysArr, valsArr)
-			queries = append(queries, qs...)
-		}
-	}
-
-	// if data is map
-	if reflect.TypeOf(data).Kind() == reflect.Map {
-		qs := s.craftForeignTableInsertStatement(data.(map[string]interface{}), fTableName, keysArr, valsArr)
-		queries = append(queries, qs...)
-	}
-
-	return queries, nil
-}
-
-// crafts insert statements according to data
-func(s *MongoOplog) craftForeignTableInsertStatement(data map[string]interface{}, fTableName string, keysArr, valsArr []string) []string {
-	queries := []string{}
-	for k, v := range data {
-		keysArr = append(keysArr, k)
-		valsArr = append(valsArr, s.convertValueToString(v))
-	}
-
-	queries = append(queries, fmt.Sprintf("INSERT INTO %s.%s_%s (%s) VALUES (%s);", s.dbName, s.tableName, fTableName, strings.Join(keysArr, ", "), strings.Join(valsArr, ", ")))
-	return queries
-}
-
-func(s *MongoOplog) getAlterTableStatement(key, val string) string {
-	return fmt.Sprintf("ALTER TABLE %s.%s ADD %s %s;", s.dbName, s.tableName, key, val)
-}
-
-// need to join with AND if multiple conditions are present
-func(s *MongoOplog) getConditionClause(conditionMap map[string]string) string {
-	var conditionClause string
-	for key, val := range conditionMap {
-		conditionClause = fmt.Sprintf("%v = %v", key, val)
-	}
-
-	return conditionClause
-}
-
-// need to join with AND if multiple conditions are present
-func(s *MongoOplog) getUpdateClause(setMap, unsetMap map[string]string) string {
-	var updateClause string
-
-	for key, val := range setMap {
-		updateClause = fmt.Sprintf("%v = %v", key, val)
-	}
-
-	// unset operation value set to NULL according to problem statement
-	for key := range unsetMap {
-		updateClause = fmt.Sprintf("%s = NULL", key)
-	}
-
-	return updateClause
-}
-
-func(s *MongoOplog) getCreateTableValues(tableCols map[string]string) []string {
-	var tableColumns []string
-	for key, val := range tableCols {
-		tableColumns = append(tableColumns, fmt.Sprintf("%v %v", key, val))
-	}
-
-	// sorting table columns to maintain consistency wrt testing
-	slices.Sort(tableColumns)
-
-	return tableColumns
-}
-
-func(s *MongoOplog) getTableColType(key string, val interface{}) string {
-	switch reflect.TypeOf(val).Kind() {
-	case reflect.String:
-		if key == "_id" {		// assuming _id is primary key
-			return " VARCHAR(255) PRIMARY KEY"
-		}
-		return " VARCHAR(255)"
-	case reflect.Float64:
-		return " FLOAT"
-	case reflect.Bool:
-		return " BOOLEAN"
-	default:
-		return ""
-	}
-}
-
-func(s *MongoOplog) convertValueToString(val interface{}) string {
-	// json unmarshalling converts all numbers to float64
-    switch reflect.TypeOf(val).Kind() {
-    case reflect.String:
-        return "'" + val.(string) + "'"
-	case reflect.Int:
-			return strconv.Itoa(int(val.(int)))
-    case reflect.Float64:
-        return strconv.FormatFloat(val.(float64), 'f', -1, 64)
-    case reflect.Bool:
-        return strconv.FormatBool(val.(bool))
-    default:
-        return ""
-    }
-}
\ No newline at end of file
+package parser
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var idKey = "_id"
+
+// MongoOplogParser is safe for concurrent use, the tables created so far are shared by all
+// the callers, so that each table is created, and each column is added, exactly once
+type MongoOplogParser struct {
+	mu sync.Mutex							// guards the tables created so far
+	cache map[string]map[string]string		// columns of the tables created so far, keyed by <db>.<table>
+	schemas map[string]bool					// schemas created so far
+	tableNames map[string]string			// original names of the tables seen so far, keyed by <db>.<table>
+	genUuid func()string
+	idempotent bool
+	dialect Dialect
+	filter *NamespaceFilter
+	mapper *NamespaceMapper
+	fieldRules *FieldRules
+	insertBatchRows int
+	compact bool
+}
+
+// Option configures the behaviour of MongoOplogParser
+type Option func(*MongoOplogParser)
+
+// WithIdempotent makes the parser emit statements which are safe to replay,
+// i.e. IF NOT EXISTS for DDL and upserts on _id for inserts. Ids of the rows
+// in nested object tables are derived from the parent _id instead of being
+// random, so that replaying an insert doesn't duplicate them. SQLite has no
+// ADD COLUMN IF NOT EXISTS, so adding a column which already exists fails
+// there, which reader.WithIgnoreDuplicateColumns takes care of for a database.
+func WithIdempotent() Option {
+	return func(m *MongoOplogParser) {
+		m.idempotent = true
+	}
+}
+
+// step of the translation of an oplog, which is either a statement, a table which has
+// to exist with the given columns before the statements following it, or the original
+// name of a table. For the columns set by an update, the table is only altered if it exists. Tables are checked against the ones created so far only while
+// resolving the steps, so that oplogs can be parsed independent of each other.
+type step struct {
+	stmt *Statement
+	dbName string
+	tableName string
+	cols map[string]string
+	alter bool
+	origName string
+}
+
+// PreparedOplog is an oplog which has been parsed, but whose statements are yet to be
+// resolved against the tables created so far
+type PreparedOplog struct {
+	s *MongoOplog
+	err error
+}
+
+// ResumeToken returns the _id of the change event, which a change stream can be resumed
+// after, or nil if the oplog is not a change event. For an array, the last one is taken.
+func(p *PreparedOplog) ResumeToken() json.RawMessage {
+	return p.s.resumeToken
+}
+
+// Timestamp returns the ts of the oplog, or the clusterTime of the change event, ok is false
+// if it has none. For an array, the last one is taken. It is known even if parsing failed,
+// as long as the oplog is valid json.
+func(p *PreparedOplog) Timestamp() (ts primitive.Timestamp, ok bool) {
+	return p.s.lastTs, p.s.hasLastTs
+}
+
+type MongoOplog struct {
+	rawOplog string
+	op string
+	dbName string
+	tableName string
+	keys []string						// keys for insert operation
+	vals []string						// values for insert operation
+	setMap map[string]string			// key-val for update set operation
+	unsetMap map[string]string			// key-val for update unset operation
+	conditionMap map[string]string		// key-val for condition clause
+	steps []step						// steps to the final sql query, resolved against the tables created so far
+	ts primitive.Timestamp				// ts of the oplog being parsed, if hasTs
+	hasTs bool
+	resumeToken json.RawMessage			// _id of the last change event, if it is one
+	lastTs primitive.Timestamp			// ts of the last oplog, if hasLastTs, even if it fails to parse
+	hasLastTs bool
+	genUuid func()string
+	idempotent bool
+	dialect Dialect
+	mapper *NamespaceMapper
+	fieldRules *FieldRules
+	cache map[string]map[string]string		// tables created before this oplog, shared with the parser
+	schemas map[string]bool					// schemas created before this oplog, shared with the parser
+	newCache map[string]map[string]string	// tables created or altered by this oplog
+	newSchemas map[string]bool				// schemas created by this oplog
+	tableNames map[string]string			// original names of the tables seen before this oplog, shared with the parser
+	newTableNames map[string]string			// original names of the tables seen first by this oplog
+}
+
+func NewMongoOplogParser(opts ...Option) *MongoOplogParser {
+	m := &MongoOplogParser{
+		genUuid: func() string {
+			return primitive.NewObjectID().Hex()
+		},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// GetEquivalentSQL returns the sql statements for the oplog joined together
+func(m *MongoOplogParser) GetEquivalentSQL(rawOplog string) (string, error) {
+	stmts, err := m.GetEquivalentSQLStatements(rawOplog)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(stmts, ""), nil
+}
+
+// GetEquivalentSQLContext is same as GetEquivalentSQL, but stops with the error of ctx
+// once it is done, i.e. between the oplogs of a large array
+func(m *MongoOplogParser) GetEquivalentSQLContext(ctx context.Context, rawOplog string) (string, error) {
+	stmts, err := m.GetEquivalentSQLStatementsContext(ctx, rawOplog)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(stmts, ""), nil
+}
+
+// GetEquivalentSQLStatements returns the sql statements for the oplog, one per element.
+// Change stream events are accepted in place of oplogs as well, and extended json
+// values like {"$oid": "..."} are treated as the plain values they stand for.
+// Transactions, i.e. applyOps oplogs, are translated to the statements of their writes.
+// Tables created by the previous calls are remembered, so that they are not created again.
+// On error, nothing is remembered from the failed oplog.
+func(m *MongoOplogParser) GetEquivalentSQLStatements(rawOplog string) ([]string, error) {
+	return m.Resolve(m.Prepare(rawOplog))
+}
+
+// GetEquivalentSQLStatementsContext is same as GetEquivalentSQLStatements, but stops with
+// the error of ctx once it is done, in which case nothing is remembered from the oplog
+func(m *MongoOplogParser) GetEquivalentSQLStatementsContext(ctx context.Context, rawOplog string) ([]string, error) {
+	return m.Resolve(m.PrepareContext(ctx, rawOplog))
+}
+
+// Prepare parses the oplog without looking at the tables created so far, so that it can
+// be called for many oplogs concurrently, i.e. from a pool of workers. The statements
+// are returned by Resolve, which has to be called for the oplogs in their original order.
+func(m *MongoOplogParser) Prepare(rawOplog string) *PreparedOplog {
+	return m.PrepareContext(context.Background(), rawOplog)
+}
+
+// PrepareContext is same as Prepare, but stops with the error of ctx once it is done
+func(m *MongoOplogParser) PrepareContext(ctx context.Context, rawOplog string) *PreparedOplog {
+	s := &MongoOplog{
+		rawOplog: rawOplog,
+		genUuid: m.genUuid,
+		idempotent: m.idempotent,
+		dialect: m.dialect,
+		mapper: m.mapper,
+		fieldRules: m.fieldRules,
+	}
+	return &PreparedOplog{s: s, err: m.prepare(ctx, s)}
+}
+
+// Resolve returns the statements for the prepared oplog, same as GetEquivalentSQLStatements,
+// adding the ddl statements for the tables which are not created yet or miss some columns
+// Concurrent callers are served one at a time, so the ddl statements for a table are returned
+// to the caller which happens to resolve first, which has to run them before the others use it.
+func(m *MongoOplogParser) Resolve(p *PreparedOplog) ([]string, error) {
+	stmts, err := m.ResolveStatements(p)
+	if err != nil {
+		return nil, err
+	}
+	return GetSQL(stmts), nil
+}
+
+// ResolveStatements is same as Resolve, but returns the statements along with the
+// tables and rows they are about
+func(m *MongoOplogParser) ResolveStatements(p *PreparedOplog) ([]Statement, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cache == nil {
+		m.cache = make(map[string]map[string]string)
+	}
+	if m.schemas == nil {
+		m.schemas = make(map[string]bool)
+	}
+	if m.tableNames == nil {
+		m.tableNames = make(map[string]string)
+	}
+
+	s := p.s
+	s.cache = m.cache
+	s.schemas = m.schemas
+	s.tableNames = m.tableNames
+	s.newCache = make(map[string]map[string]string)
+	s.newSchemas = make(map[string]bool)
+	s.newTableNames = make(map[string]string)
+
+	stmts, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	// remembering the tables created by this oplog for the next calls
+	for table, cols := range s.newCache {
+		m.cache[table] = cols
+	}
+	for schema := range s.newSchemas {
+		m.schemas[schema] = true
+	}
+	for table, name := range s.newTableNames {
+		m.tableNames[table] = name
+	}
+
+	return stmts, nil
+}
+
+// parses the oplog into the steps for its statements
+func(m *MongoOplogParser) prepare(ctx context.Context, s *MongoOplog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// unmarshalling the raw oplog
+	var obj interface{}
+	err := json.Unmarshal([]byte(s.rawOplog), &obj)
+	if err != nil {
+		return err
+	}
+	obj = normalizeExtendedJSON(obj)
+
+	// to handle both type, slice of json and single json
+	var items []interface{}
+	switch v := obj.(type) {
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		items = append(items, v)
+	default:
+		return fmt.Errorf("error: oplog must be a json object or an array of json objects")
+	}
+	if len(items) > 0 {
+		if last, ok := items[len(items)-1].(map[string]interface{}); ok {
+			s.lastTs, s.hasLastTs = GetOplogTimestamp(last)
+		}
+	}
+
+	// change stream events are converted to the equivalent oplogs
+	var result []map[string]interface{}
+	for i, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("error: oplog at index %d is not a json object", i)
+		}
+
+		oplogs := []map[string]interface{}{obj}
+		s.resumeToken = nil
+		if isChangeEvent(obj) {
+			oplogs, err = changeEventToOplogs(obj)
+			if err != nil {
+				return err
+			}
+			s.resumeToken = getResumeToken(obj)
+		} else if isApplyOps(obj) {
+			oplogs, err = applyOpsToOplogs(obj)
+			if err != nil {
+				return err
+			}
+		}
+
+		// skipping the namespaces which are filtered out, invalid ones are left to parse
+		for _, oplog := range oplogs {
+			if ns, ok := oplog["ns"].(string); ok && m.filter != nil && !m.filter.Match(ns) {
+				continue
+			}
+			result = append(result, oplog)
+		}
+	}
+
+	// parsing the raw oplog
+	for _, r := range result {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err = s.parse(r)
+		if err != nil {
+			return err
+		}
+
+		// nested objects are only handled for insert operation
+		if s.op != "i" {
+			continue
+		}
+
+		// if r has nested objects and has _id key, then proceed further
+		nestedMap := r["o"].(map[string]interface{})
+		parentObjVal, ok := nestedMap[idKey]
+		if !ok {
+			continue
+		}
+		if parentObjVal == nil || isNested(parentObjVal) {
+			return fmt.Errorf("error: unsupported %s value %v: failed to link nested objects", idKey, parentObjVal)
+		}
+
+		// preparing parent object key
+		parentObjKey := s.tableName + "_" + idKey
+
+		// handling nested objects separetly for create table and insert statement
+		// arrays are handled before objects, both in sorted order of keys
+		// to maintain consistency wrt testing
+		keys := GetSortedKeys(nestedMap)
+		for _, key := range keys {
+			if _, ok := nestedMap[key].([]interface{}); ok {
+				err = s.handleForeignTable(nestedMap[key], key, parentObjKey, parentObjVal)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, key := range keys {
+			if _, ok := nestedMap[key].(map[string]interface{}); ok {
+				err = s.handleForeignTable(nestedMap[key], key, parentObjKey, parentObjVal)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolves the steps into the statements, as per the tables created so far
+func(s *MongoOplog) resolve() ([]Statement, error) {
+	var stmts []Statement
+	for _, st := range s.steps {
+		switch {
+		case st.stmt != nil:
+			stmts = append(stmts, *st.stmt)
+		case st.cols != nil:
+			s.dbName = st.dbName
+			if st.alter && !s.isTableCreated(st.tableName) {
+				continue
+			}
+			stmts = append(stmts, s.getSchemaStatements(st.tableName, st.cols)...)
+		default:
+			if err := s.recordTableName(st.dbName, st.tableName, st.origName); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stmts, nil
+}
+
+// GetTableNames returns the original names of the tables seen so far, i.e. test.student.archive
+// for the table test.student_archive, keyed by <db>.<table>. Original names are the ones given
+// by the NamespaceMapper, if any, before being made valid identifiers.
+func(m *MongoOplogParser) GetTableNames() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.tableNames)
+}
+
+// GetSchemaStatements returns the statements to create all the tables seen so far,
+// each with the final set of columns, instead of the create and alter statements
+// which were returned while handling the oplogs one by one
+func(m *MongoOplogParser) GetSchemaStatements() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stmts []string
+	createdSchemas := make(map[string]bool)
+	for _, cacheKey := range GetSortedKeys(m.cache) {
+		dbName, tableName, _ := strings.Cut(cacheKey, ".")
+		s := &MongoOplog{
+			dbName: dbName,
+			idempotent: m.idempotent,
+			dialect: m.dialect,
+		}
+
+		if !createdSchemas[dbName] {
+			if stmt := s.getCreateSchemaStatement(); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			createdSchemas[dbName] = true
+		}
+		stmts = append(stmts, s.getCreateTableStatement(tableName, s.getCreateTableValues(m.cache[cacheKey])))
+	}
+	return stmts
+}
+
+func(s *MongoOplog) parse(result map[string]interface{}) error {
+	op, ok := result["op"].(string)
+	if !ok || (op != "i" && op != "u" && op != "d") {
+		return fmt.Errorf("error: unsupported operation type %q", result["op"])
+	}
+	s.op = op
+	s.ts, s.hasTs = GetOplogTimestamp(result)
+
+	ns, ok := result["ns"].(string)
+	if !ok {
+		return fmt.Errorf("error: ns key not found in the oplog: failed to set the table name")
+	}
+	dbName, tableName, err := parseNamespace(ns)
+	if err != nil {
+		return err
+	}
+	if s.mapper != nil {
+		dbName, tableName = s.mapper.Map(dbName, tableName)
+	}
+
+	// names are made valid identifiers, i.e. student.archive becomes student_archive
+	s.dbName = sanitizeIdentifier(dbName)
+	s.tableName = sanitizeIdentifier(tableName)
+	s.steps = append(s.steps, step{dbName: s.dbName, tableName: s.tableName, origName: dbName + "." + tableName})
+
+	// dropping, renaming and masking the fields as per the rules for the namespace
+	if s.fieldRules != nil {
+		skip, err := s.applyFieldRules(ns, result)
+		if err != nil {
+			return err
+		}
+		if skip {
+			return nil
+		}
+	}
+
+	nestedMap, ok := result["o"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("error: o key not found in the oplog: failed to set keys and values")
+	}
+
+   	if s.op == "i" {	// on insert operation
+		tableCols := make(map[string]string)
+		keys := make([]string, 0, len(nestedMap))
+		vals := make([]interface{}, 0, len(nestedMap))
+		
+		// extracts the insert key and values, in sorted order of keys
+		for _, key := range GetSortedKeys(nestedMap) {
+			val := nestedMap[key]
+
+			// skip if value is map, slice or null
+			if isNested(val) || val == nil {
+				continue
+			}
+
+			// adding key and value for query generation
+			tableCols[key] = s.getTableColType(key, val)
+			keys = append(keys, key)
+			vals = append(vals, val)
+		}
+
+		if len(keys) == 0 {
+			return fmt.Errorf("error: no columns found while inserting")
+		}
+
+		// creates the table on first insert, and alters it if any new key is found afterwards
+		s.addTable(s.tableName, tableCols)
+
+		s.addInsert(s.tableName, keys, vals)
+	} else if s.op == "u" {		// on update operation
+		nestedMap, ok = nestedMap["diff"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("error: diff key not found in the oplog: failed to set keys and values")
+		}
+
+		// changes within the nested fields come as subdiffs, s<field>, which have no columns
+		for _, key := range GetSortedKeys(nestedMap) {
+			if len(key) > 1 && key[0] == 's' {
+				return fmt.Errorf("error: unsupported update of nested field %q", key[1:])
+			}
+		}
+
+		// extracts the update set key and value, from both the updated (u) and the added (i) fields
+		setMap := make(map[string]interface{})
+		for _, setKey := range []string{"u", "i"} {
+			if nestedMap[setKey] == nil {
+				continue
+			}
+			setFields, ok := nestedMap[setKey].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("error: diff.%s is not a json object: failed to set keys and values", setKey)
+			}
+			for key, val := range setFields {
+				if isNested(val) {
+					return fmt.Errorf("error: unsupported nested value for %q while updating", key)
+				}
+				setMap[key] = val
+			}
+		}
+
+		// extracts the update unset key
+		var unsetKeys []string
+		if nestedMap["d"] != nil {
+			unsetFields, ok := nestedMap["d"].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("error: diff.d is not a json object: failed to set keys and values")
+			}
+			unsetKeys = GetSortedKeys(unsetFields)
+		}
+
+		// extracts the update condition
+		conditionMap := make(map[string]interface{})
+		if result["o2"] != nil {
+			conditionFields, ok := result["o2"].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("error: o2 is not a json object: failed to set the condition")
+			}
+			for key, val := range conditionFields {
+				if isNested(val) {
+					return fmt.Errorf("error: unsupported nested value for %q in condition", key)
+				}
+				conditionMap[key] = val
+			}
+		}
+
+		if len(setMap) == 0 && len(unsetKeys) == 0 {
+			return fmt.Errorf("error: update clause not found while updating")
+		}
+		if len(conditionMap) == 0 {
+			return fmt.Errorf("error: condition clause not found while updating")
+		}
+
+		// alters the table for the columns which are set for the first time, null has no type
+		tableCols := make(map[string]string)
+		for key, val := range setMap {
+			if colType := s.getTableColType(key, val); colType != "" {
+				tableCols[key] = colType
+			}
+		}
+		if len(tableCols) > 0 {
+			s.addColumns(s.tableName, tableCols)
+		}
+
+		s.addUpdate(s.tableName, setMap, unsetKeys, conditionMap)
+	} else if s.op == "d" {		// on delete operation
+		conditionMap := make(map[string]interface{})
+		for key, val := range nestedMap {
+			if isNested(val) {
+				return fmt.Errorf("error: unsupported nested value for %q in condition", key)
+			}
+			conditionMap[key] = val
+		}
+
+		if len(conditionMap) == 0 {
+			return fmt.Errorf("error: condition clause not found while deleting")
+		}
+
+		s.addDelete(s.tableName, conditionMap)
+	}
+	return nil
+}
+
+// handles create table and insert statements for a nested object
+func(s *MongoOplog) handleForeignTable(data interface{}, fTableName, parentObjKey string, parentObjVal interface{}) error {
+	rows, err := getForeignTableRows(data, fTableName)
+	if err != nil {
+		return err
+	}
+
+	fTable := s.mapper.mapNestedTable(s.tableName, fTableName)
+	for i, row := range rows {
+		tableCols := make(map[string]string)
+		keysArr := []string{idKey, parentObjKey}
+		valsArr := []interface{}{s.getForeignRowId(fTableName, parentObjVal, i), parentObjVal}
+
+		// saving two id columns first
+		tableCols[idKey] = s.getTableColType(idKey, s.genUuid())
+		tableCols[parentObjKey] = s.getTableColType(parentObjKey, parentObjVal)
+
+		for _, key := range GetSortedKeys(row) {
+			if row[key] == nil {
+				continue
+			}
+			tableCols[key] = s.getTableColType(key, row[key])
+			keysArr = append(keysArr, key)
+			valsArr = append(valsArr, row[key])
+		}
+
+		// creates the table for the first row, and alters it if any new key is found afterwards
+		s.addTable(fTable, tableCols)
+		s.addInsert(fTable, keysArr, valsArr)
+	}
+	return nil
+}
+
+// returns the _id for a row of the nested object table
+// in idempotent mode, it is derived from the parent _id and position of the row
+func(s *MongoOplog) getForeignRowId(fTableName string, parentObjVal interface{}, index int) string {
+	if !s.idempotent {
+		return s.genUuid()
+	}
+
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s.%s_%s|%v|%d", s.dbName, s.tableName, fTableName, parentObjVal, index)))
+	return hex.EncodeToString(sum[:12])
+}
+
+// validates the nested object and returns it as rows of the foreign table
+// only array of json objects or a json object with scalar values are supported
+func getForeignTableRows(data interface{}, fTableName string) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	switch v := data.(type) {
+	case []interface{}:
+		for i, item := range v {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("error: unsupported value at index %d of %q: only array of json objects is supported", i, fTableName)
+			}
+			rows = append(rows, row)
+		}
+	case map[string]interface{}:
+		rows = append(rows, v)
+	default:
+		return nil, fmt.Errorf("error: unsupported value for %q: expected json object or array", fTableName)
+	}
+
+	for _, row := range rows {
+		for key, val := range row {
+			if isNested(val) {
+				return nil, fmt.Errorf("error: unsupported nested value for %q in %q", key, fTableName)
+			}
+		}
+	}
+	return rows, nil
+}
+
+func(s *MongoOplog) addUpdate(tableName string, setMap map[string]interface{}, unsetKeys []string, conditionMap map[string]interface{}) {
+	s.steps = append(s.steps, step{stmt: &Statement{
+		Kind: Update,
+		DBName: s.dbName,
+		TableName: tableName,
+		Set: setMap,
+		Unset: unsetKeys,
+		Where: conditionMap,
+		Ts: s.ts,
+		HasTs: s.hasTs,
+		SQL: s.getUpdateStatement(tableName, setMap, unsetKeys, conditionMap),
+	}})
+}
+
+func(s *MongoOplog) addDelete(tableName string, conditionMap map[string]interface{}) {
+	s.steps = append(s.steps, step{stmt: &Statement{
+		Kind: Delete,
+		DBName: s.dbName,
+		TableName: tableName,
+		Where: conditionMap,
+		Ts: s.ts,
+		HasTs: s.hasTs,
+		SQL: s.getDeleteStatement(tableName, conditionMap),
+	}})
+}
+
+func(s *MongoOplog) addInsert(tableName string, keys []string, vals []interface{}) {
+	rows := [][]interface{}{vals}
+	types := make([]string, 0, len(vals))
+	for _, val := range vals {
+		types = append(types, GetColumnType(val))
+	}
+	s.steps = append(s.steps, step{stmt: &Statement{
+		Kind: Insert,
+		DBName: s.dbName,
+		TableName: tableName,
+		Columns: keys,
+		Types: types,
+		Rows: rows,
+		Ts: s.ts,
+		HasTs: s.hasTs,
+		SQL: s.getInsertStatement(tableName, keys, rows),
+	}})
+}
+
+// makes sure that the table exists with the columns before the statements added next
+func(s *MongoOplog) addTable(tableName string, tableCols map[string]string) {
+	s.steps = append(s.steps, step{dbName: s.dbName, tableName: tableName, cols: tableCols})
+}
+
+// makes sure that the table has the columns before the statements added next, if it exists
+func(s *MongoOplog) addColumns(tableName string, tableCols map[string]string) {
+	s.steps = append(s.steps, step{dbName: s.dbName, tableName: tableName, cols: tableCols, alter: true})
+}
+
+// checks if the table has been created, either before or by this oplog
+func(s *MongoOplog) isTableCreated(tableName string) bool {
+	cacheKey := s.dbName + "." + tableName
+	_, inNew := s.newCache[cacheKey]
+	_, inCache := s.cache[cacheKey]
+	return inNew || inCache
+}
+
+// returns the ddl statements needed for the table to hold the given columns
+// schema and table are created on first use, and table is altered for new columns afterwards
+func(s *MongoOplog) getSchemaStatements(tableName string, tableCols map[string]string) []Statement {
+	var stmts []Statement
+	cacheKey := s.dbName + "." + tableName
+
+	cachedCols, ok := s.newCache[cacheKey]
+	if !ok {
+		cachedCols, ok = s.cache[cacheKey]
+	}
+
+	if !ok {
+		if !s.schemas[s.dbName] && !s.newSchemas[s.dbName] {
+			if stmt := s.getCreateSchemaStatement(); stmt != "" {
+				stmts = append(stmts, Statement{Kind: DDL, DBName: s.dbName, SQL: stmt})
+			}
+			s.newSchemas[s.dbName] = true
+		}
+		stmt := s.getDDLStatement(tableName, GetSortedKeys(tableCols), tableCols)
+		stmt.SQL = s.getCreateTableStatement(tableName, s.getCreateTableValues(tableCols))
+		stmts = append(stmts, stmt)
+		s.newCache[cacheKey] = maps.Clone(tableCols)
+		return stmts
+	}
+
+	// cached columns are copied, so that they remain intact if this oplog fails
+	var newCols map[string]string
+	for _, key := range GetSortedKeys(tableCols) {
+		if _, ok := cachedCols[key]; ok {
+			continue
+		}
+		if newCols == nil {
+			newCols = maps.Clone(cachedCols)
+		}
+		newCols[key] = tableCols[key]
+		stmt := s.getDDLStatement(tableName, []string{key}, tableCols)
+		stmt.SQL = s.getAlterTableStatement(tableName, key, tableCols[key])
+		stmts = append(stmts, stmt)
+	}
+	if newCols != nil {
+		s.newCache[cacheKey] = newCols
+	}
+	return stmts
+}
+
+// returns the ddl statement adding the given columns to the table, without the sql
+func(s *MongoOplog) getDDLStatement(tableName string, cols []string, tableCols map[string]string) Statement {
+	types := make([]string, 0, len(cols))
+	for _, col := range cols {
+		types = append(types, strings.TrimSpace(strings.TrimSuffix(tableCols[col], " PRIMARY KEY")))
+	}
+	return Statement{Kind: DDL, DBName: s.dbName, TableName: tableName, Columns: cols, Types: types}
+}
+
+func(s *MongoOplog) getAlterTableStatement(tableName, key, val string) string {
+	if s.idempotent && s.dialect != SQLite {
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;", s.getQualifiedTableName(tableName), key, val)
+	}
+	if s.dialect == SQLite {
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", s.getQualifiedTableName(tableName), key, val)
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD %s %s;", s.getQualifiedTableName(tableName), key, val)
+}
+
+// sqlite has no schemas, so nothing is returned for it
+func(s *MongoOplog) getCreateSchemaStatement() string {
+	if s.dialect == SQLite {
+		return ""
+	}
+	if s.idempotent {
+		return fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", s.dbName)
+	}
+	return fmt.Sprintf("CREATE SCHEMA %s;", s.dbName)
+}
+
+func(s *MongoOplog) getCreateTableStatement(tableName string, cols []string) string {
+	if s.idempotent {
+		return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", s.getQualifiedTableName(tableName), strings.Join(cols, ", "))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s);", s.getQualifiedTableName(tableName), strings.Join(cols, ", "))
+}
+
+// in idempotent mode, insert turns into an upsert on _id
+// so that replaying it overwrites the row with the same values
+func(s *MongoOplog) getInsertStatement(tableName string, keys []string, rows [][]interface{}) string {
+	tuples := make([]string, 0, len(rows))
+	for _, row := range rows {
+		vals := make([]string, 0, len(row))
+		for _, val := range row {
+			vals = append(vals, s.convertValueToString(val))
+		}
+		tuples = append(tuples, "(" + strings.Join(vals, ", ") + ")")
+	}
+
+	insertQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", s.getQualifiedTableName(tableName), strings.Join(keys, ", "), strings.Join(tuples, ", "))
+	if !s.idempotent {
+		return insertQuery + ";"
+	}
+
+	var updateCols []string
+	for _, key := range keys {
+		if key == idKey {
+			continue
+		}
+		updateCols = append(updateCols, fmt.Sprintf("%s = EXCLUDED.%s", key, key))
+	}
+
+	if len(updateCols) == 0 {
+		return fmt.Sprintf("%s ON CONFLICT (%s) DO NOTHING;", insertQuery, idKey)
+	}
+	return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s;", insertQuery, idKey, strings.Join(updateCols, ", "))
+}
+
+func(s *MongoOplog) getUpdateStatement(tableName string, setMap map[string]interface{}, unsetKeys []string, conditionMap map[string]interface{}) string {
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", s.getQualifiedTableName(tableName), s.getUpdateClause(setMap, unsetKeys), s.getConditionClause(conditionMap))
+}
+
+func(s *MongoOplog) getDeleteStatement(tableName string, conditionMap map[string]interface{}) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s;", s.getQualifiedTableName(tableName), s.getConditionClause(conditionMap))
+}
+
+// joins the conditions with AND, in sorted order of keys
+func(s *MongoOplog) getConditionClause(conditionMap map[string]interface{}) string {
+	conditions := make([]string, 0, len(conditionMap))
+	for _, key := range GetSortedKeys(conditionMap) {
+		conditions = append(conditions, fmt.Sprintf("%v = %v", key, s.convertValueToString(conditionMap[key])))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// joins the set columns, in sorted order of keys, followed by the unset ones
+func(s *MongoOplog) getUpdateClause(setMap map[string]interface{}, unsetKeys []string) string {
+	updates := make([]string, 0, len(setMap) + len(unsetKeys))
+	for _, key := range GetSortedKeys(setMap) {
+		updates = append(updates, fmt.Sprintf("%v = %v", key, s.convertValueToString(setMap[key])))
+	}
+
+	// unset operation value set to NULL according to problem statement
+	for _, key := range unsetKeys {
+		updates = append(updates, fmt.Sprintf("%s = NULL", key))
+	}
+	return strings.Join(updates, ", ")
+}
+
+func(s *MongoOplog) getCreateTableValues(tableCols map[string]string) []string {
+	var tableColumns []string
+	for key, val := range tableCols {
+		tableColumns = append(tableColumns, fmt.Sprintf("%v %v", key, val))
+	}
+
+	// sorting table columns to maintain consistency wrt testing
+	slices.Sort(tableColumns)
+
+	return tableColumns
+}
+
+func(s *MongoOplog) getTableColType(key string, val interface{}) string {
+	if val == nil {
+		return ""
+	}
+
+	// assuming _id is primary key, irrespective of its type
+	if key == idKey {
+		colType := s.getTableColType("", val)
+		if colType == "" {
+			return ""
+		}
+		return colType + " PRIMARY KEY"
+	}
+
+	switch reflect.TypeOf(val).Kind() {
+	case reflect.String:
+		return " VARCHAR(255)"
+	case reflect.Int64, reflect.Float64:
+		return " FLOAT"
+	case reflect.Bool:
+		return " BOOLEAN"
+	default:
+		return ""
+	}
+}
+
+func(s *MongoOplog) convertValueToString(val interface{}) string {
+	// json unmarshalling converts all numbers to float64
+	if val == nil {
+		return "NULL"
+	}
+
+    switch reflect.TypeOf(val).Kind() {
+    case reflect.String:
+        // quotes are doubled, so that the value can't end the literal
+        return "'" + strings.ReplaceAll(val.(string), "'", "''") + "'"
+	case reflect.Int:
+			return strconv.Itoa(int(val.(int)))
+	case reflect.Int64:
+		return strconv.FormatInt(val.(int64), 10)
+    case reflect.Float64:
+        return strconv.FormatFloat(val.(float64), 'f', -1, 64)
+    case reflect.Bool:
+        return strconv.FormatBool(val.(bool))
+    default:
+        return ""
+    }
+}
+
+// checks if the value is a nested object or array
+func isNested(val interface{}) bool {
+	switch val.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetColumnType returns the sql type of the column as per its value, i.e. FLOAT
+// for numbers, or an empty string for null and the nested values
+func GetColumnType(val interface{}) string {
+	return strings.TrimSpace((&MongoOplog{}).getTableColType("", val))
+}
+
+// GetOplogTimestamp returns the ts of the oplog, or the clusterTime of the change stream event,
+// which is either {"t": ..., "i": ...} or the same wrapped in $timestamp, ok is false if it has none
+func GetOplogTimestamp(oplog map[string]interface{}) (ts primitive.Timestamp, ok bool) {
+	tsMap, isMap := oplog["ts"].(map[string]interface{})
+	if !isMap {
+		tsMap, isMap = oplog["clusterTime"].(map[string]interface{})
+	}
+	if !isMap {
+		return ts, false
+	}
+	if nested, isMap := tsMap["$timestamp"].(map[string]interface{}); isMap {
+		tsMap = nested
+	}
+
+	t, tOk := tsMap["t"].(float64)
+	i, iOk := tsMap["i"].(float64)
+	if !tOk || !iOk || t < 0 || i < 0 {
+		return ts, false
+	}
+	return primitive.Timestamp{T: uint32(t), I: uint32(i)}, true
+}
+
+// splits the namespace into database and collection name
+// splits the namespace on the first dot, as collection names can have dots but database names can't
+func parseNamespace(ns string) (string, string, error) {
+	dbName, collName, ok := strings.Cut(ns, ".")
+	if !ok || dbName == "" || collName == "" {
+		return "", "", fmt.Errorf("error: invalid namespace %q: expected <db>.<collection>", ns)
+	}
+	return dbName, collName, nil
+}
+
+// replaces the characters which are not allowed in an unquoted identifier with _
+// and prefixes the name with _ if it starts with a digit
+func sanitizeIdentifier(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// records the original name of the table, it is an error if another name was
+// already sanitized to the same table, as their rows would be merged otherwise
+func(s *MongoOplog) recordTableName(dbName, tableName, name string) error {
+	table := dbName + "." + tableName
+	recorded, ok := s.tableNames[table]
+	if !ok {
+		recorded, ok = s.newTableNames[table]
+	}
+	if ok && recorded != name {
+		return fmt.Errorf("error: %q and %q both map to the table %s", recorded, name, table)
+	}
+	if !ok {
+		s.newTableNames[table] = name
+	}
+	return nil
+}
+
+// GetSortedKeys returns the keys of the map in sorted order
+func GetSortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -1,257 +1,820 @@
-package parser
-
-import (
-	"testing"
-
-	pgquery "github.com/pganalyze/pg_query_go/v5"
-)
-
-func NewMockMongoOplogParser() *MongoOplogParser {
-	return &MongoOplogParser{
-		genUuid: func() string {
-			return "14798c213f273a7ca2cf5174"
-		},
-	}
-}
-
-func TestMongoOplogParser(t *testing.T) {
-	tt := []struct {
-		name string
-		input string
-		exp string
-	}{
-		{
-			name: "create table with insert statement",
-			input: `{
-				"op": "i",
-				"ns": "test.student",
-				"o": {
-					"_id": "635b79e231d82a8ab1de863b",
-					"name": "Selena Miller",
-					"roll_no": 51,
-					"is_graduated": false,
-					"date_of_birth": "2000-01-30"
-				}
-			}`,
-			exp: `
-				CREATE SCHEMA test;
-				CREATE TABLE test.student
-				(
-					_id           VARCHAR(255) PRIMARY KEY,
-					date_of_birth VARCHAR(255),
-					is_graduated  BOOLEAN,
-					name          VARCHAR(255),
-					roll_no       FLOAT
-				);
-				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
-			`,
-		},
-		{
-			name: "update statement set operation",
-			input: `{
-				"op": "u",
-				"ns": "test.student",
-				"o": {
-					"$v": 2,
-					"diff": {
-						"u": {
-							"is_graduated": true
-						}
-					}	
-				},
-					"o2": {
-					"_id": "635b79e231d82a8ab1de863b"
-				}
-			}`,
-			exp: "UPDATE test.student SET is_graduated = true WHERE _id = '635b79e231d82a8ab1de863b';",
-		},
-		{
-			name: "update statement unset operation",
-			input: `{
-				"op": "u",
-				"ns": "test.student",
-				"o": {
-					"$v": 2,
-					"diff": {
-						"d": {
-							"roll_no": false
-						}
-					}
-				},
-				"o2": {
-					"_id": "635b79e231d82a8ab1de863b"
-				}
-			}`,
-			exp: "UPDATE test.student SET roll_no = NULL WHERE _id = '635b79e231d82a8ab1de863b';",
-		},
-		{
-			name: "delete statement",
-			input: `{
-				"op": "d",
-				"ns": "test.student",
-				"o": {
-					"_id": "635b79e231d82a8ab1de863b"
-				}
-			}`,
-			exp: "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';",
-		},
-		{
-			name: "create table with multiple insert statement",
-			input: `[
-				{
-					"op": "i",
-					"ns": "test.student",
-					"o": {
-					"_id": "635b79e231d82a8ab1de863b",
-					"name": "Selena Miller",
-					"roll_no": 51,
-					"is_graduated": false,
-					"date_of_birth": "2000-01-30"
-					}
-				},
-				{
-					"op": "i",
-					"ns": "test.student",
-					"o": {
-					"_id": "14798c213f273a7ca2cf5174",
-					"name": "George Smith",
-					"roll_no": 21,
-					"is_graduated": true,
-					"date_of_birth": "2001-03-23"
-					}
-				}
-			]`,
-			exp: `
-				CREATE SCHEMA test;
-				CREATE TABLE test.student
-				(
-					_id           VARCHAR(255) PRIMARY KEY,
-					date_of_birth VARCHAR(255),
-					is_graduated  BOOLEAN,
-					name          VARCHAR(255),
-					roll_no       FLOAT
-				);
-				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
-				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', '2001-03-23', true, 'George Smith', 21);
-			`,
-		},
-		{
-			name: "create and alter table with multiple insert statement",
-			input: `[
-				{
-					"op": "i",
-					"ns": "test.student",
-					"o": {
-					"_id": "635b79e231d82a8ab1de863b",
-					"name": "Selena Miller",
-					"roll_no": 51,
-					"is_graduated": false,
-					"date_of_birth": "2000-01-30"
-					}
-				},
-				{
-					"op": "i",
-					"ns": "test.student",
-					"o": {
-					"_id": "14798c213f273a7ca2cf5174",
-					"name": "George Smith",
-					"roll_no": 21,
-					"is_graduated": true,
-					"date_of_birth": "2001-03-23",
-					"phone": "+91-81254966457"
-					}
-				}
-			]`,
-			exp: `
-				CREATE SCHEMA test;
-				CREATE TABLE test.student
-				(
-					_id           VARCHAR(255) PRIMARY KEY,
-					date_of_birth VARCHAR(255),
-					is_graduated  BOOLEAN,
-					name          VARCHAR(255),
-					roll_no       FLOAT
-				);
-				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
-				ALTER TABLE test.student ADD phone VARCHAR(255);
-				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, phone, roll_no) VALUES ('14798c213f273a7ca2cf5174', '2001-03-23', true, 'George Smith', '+91-81254966457', 21);
-			`,
-		},
-		// this test case exp id is modified to match with the mock uuid and query equivalence
-		{
-			name: "handling nested objects",
-			input: `{
-				"op": "i",
-				"ns": "test.student",
-				"o": {
-					"_id": "635b79e231d82a8ab1de863b",
-					"name": "Selena Miller",
-					"roll_no": 51,
-					"is_graduated": false,
-					"date_of_birth": "2000-01-30",
-					"address": [
-					{
-						"line1": "481 Harborsburgh",
-						"zip": "89799"
-					},
-					{
-						"line1": "329 Flatside",
-						"zip": "80872"
-					}
-					],
-					"phone": {
-					"personal": "7678456640",
-					"work": "8130097989"
-					}
-			}}`,
-			exp: `
-				CREATE SCHEMA test;
-				CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, date_of_birth VARCHAR(255), is_graduated BOOLEAN, name VARCHAR(255), roll_no FLOAT);
-				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
-				CREATE TABLE test.student_address (_id VARCHAR(255) PRIMARY KEY, line1 VARCHAR(255), student__id VARCHAR(255), zip VARCHAR(255));
-				INSERT INTO test.student_address (_id, line1, student__id, zip) VALUES ('14798c213f273a7ca2cf5174', '481 Harborsburgh', '635b79e231d82a8ab1de863b', '89799');
-				INSERT INTO test.student_address (_id, line1, student__id, zip) VALUES ('14798c213f273a7ca2cf5174', '329 Flatside', '635b79e231d82a8ab1de863b', '80872');
-				CREATE TABLE test.student_phone (_id VARCHAR(255) PRIMARY KEY, personal VARCHAR(255), student__id VARCHAR(255), work VARCHAR(255));
-				INSERT INTO test.student_phone (_id, personal, student__id, work) VALUES ('14798c213f273a7ca2cf5174', '7678456640', '635b79e231d82a8ab1de863b', '8130097989');
-			`,
-		},
-	}
-
-	for _, tc := range tt {
-		t.Run(tc.name, func(t *testing.T) {
-			m := NewMockMongoOplogParser()
-			
-			got, err := m.GetEquivalentSQL(tc.input)
-			if err != nil {
-				t.Errorf("Error: %v", err)
-			}
-
-			result, err := compareSqlStatement(t, tc.exp, got)
-			if err != nil {
-				t.Fatalf("Error while comparing SQL statements: %v", err)
-			}
-
-			if !result {
-				t.Errorf("Expected %q but got %q", tc.exp, got)
-			}
-		})
-	}
-}
-
-// compares if sql statements are equal on the basis of fingerprint
-// if they are equivalent, fingerprint will be same
-func compareSqlStatement(t *testing.T, expected, got string) (bool, error) {
-	t.Helper()
-
-	expFp, err := pgquery.Fingerprint(expected)
-	if err != nil {
-		return false, err
-	}
-
-	gotFp, err := pgquery.Fingerprint(got)
-	if err != nil {
-		return false, err
-	}
-
-	return expFp == gotFp, nil
-}
\ No newline at end of file
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	pgquery "github.com/pganalyze/pg_query_go/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func NewMockMongoOplogParser() *MongoOplogParser {
+	return &MongoOplogParser{
+		genUuid: func() string {
+			return "14798c213f273a7ca2cf5174"
+		},
+	}
+}
+
+func TestMongoOplogParser(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		exp string
+	}{
+		{
+			name: "create table with insert statement",
+			input: `{
+				"op": "i",
+				"ns": "test.student",
+				"o": {
+					"_id": "635b79e231d82a8ab1de863b",
+					"name": "Selena Miller",
+					"roll_no": 51,
+					"is_graduated": false,
+					"date_of_birth": "2000-01-30"
+				}
+			}`,
+			exp: `
+				CREATE SCHEMA test;
+				CREATE TABLE test.student
+				(
+					_id           VARCHAR(255) PRIMARY KEY,
+					date_of_birth VARCHAR(255),
+					is_graduated  BOOLEAN,
+					name          VARCHAR(255),
+					roll_no       FLOAT
+				);
+				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
+			`,
+		},
+		{
+			name: "update statement set operation",
+			input: `{
+				"op": "u",
+				"ns": "test.student",
+				"o": {
+					"$v": 2,
+					"diff": {
+						"u": {
+							"is_graduated": true
+						}
+					}	
+				},
+					"o2": {
+					"_id": "635b79e231d82a8ab1de863b"
+				}
+			}`,
+			exp: "UPDATE test.student SET is_graduated = true WHERE _id = '635b79e231d82a8ab1de863b';",
+		},
+		{
+			name: "update statement unset operation",
+			input: `{
+				"op": "u",
+				"ns": "test.student",
+				"o": {
+					"$v": 2,
+					"diff": {
+						"d": {
+							"roll_no": false
+						}
+					}
+				},
+				"o2": {
+					"_id": "635b79e231d82a8ab1de863b"
+				}
+			}`,
+			exp: "UPDATE test.student SET roll_no = NULL WHERE _id = '635b79e231d82a8ab1de863b';",
+		},
+		{
+			name: "delete statement",
+			input: `{
+				"op": "d",
+				"ns": "test.student",
+				"o": {
+					"_id": "635b79e231d82a8ab1de863b"
+				}
+			}`,
+			exp: "DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';",
+		},
+		{
+			name: "create table with multiple insert statement",
+			input: `[
+				{
+					"op": "i",
+					"ns": "test.student",
+					"o": {
+					"_id": "635b79e231d82a8ab1de863b",
+					"name": "Selena Miller",
+					"roll_no": 51,
+					"is_graduated": false,
+					"date_of_birth": "2000-01-30"
+					}
+				},
+				{
+					"op": "i",
+					"ns": "test.student",
+					"o": {
+					"_id": "14798c213f273a7ca2cf5174",
+					"name": "George Smith",
+					"roll_no": 21,
+					"is_graduated": true,
+					"date_of_birth": "2001-03-23"
+					}
+				}
+			]`,
+			exp: `
+				CREATE SCHEMA test;
+				CREATE TABLE test.student
+				(
+					_id           VARCHAR(255) PRIMARY KEY,
+					date_of_birth VARCHAR(255),
+					is_graduated  BOOLEAN,
+					name          VARCHAR(255),
+					roll_no       FLOAT
+				);
+				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
+				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', '2001-03-23', true, 'George Smith', 21);
+			`,
+		},
+		{
+			name: "create and alter table with multiple insert statement",
+			input: `[
+				{
+					"op": "i",
+					"ns": "test.student",
+					"o": {
+					"_id": "635b79e231d82a8ab1de863b",
+					"name": "Selena Miller",
+					"roll_no": 51,
+					"is_graduated": false,
+					"date_of_birth": "2000-01-30"
+					}
+				},
+				{
+					"op": "i",
+					"ns": "test.student",
+					"o": {
+					"_id": "14798c213f273a7ca2cf5174",
+					"name": "George Smith",
+					"roll_no": 21,
+					"is_graduated": true,
+					"date_of_birth": "2001-03-23",
+					"phone": "+91-81254966457"
+					}
+				}
+			]`,
+			exp: `
+				CREATE SCHEMA test;
+				CREATE TABLE test.student
+				(
+					_id           VARCHAR(255) PRIMARY KEY,
+					date_of_birth VARCHAR(255),
+					is_graduated  BOOLEAN,
+					name          VARCHAR(255),
+					roll_no       FLOAT
+				);
+				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
+				ALTER TABLE test.student ADD phone VARCHAR(255);
+				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, phone, roll_no) VALUES ('14798c213f273a7ca2cf5174', '2001-03-23', true, 'George Smith', '+91-81254966457', 21);
+			`,
+		},
+		// this test case exp id is modified to match with the mock uuid and query equivalence
+		{
+			name: "handling nested objects",
+			input: `{
+				"op": "i",
+				"ns": "test.student",
+				"o": {
+					"_id": "635b79e231d82a8ab1de863b",
+					"name": "Selena Miller",
+					"roll_no": 51,
+					"is_graduated": false,
+					"date_of_birth": "2000-01-30",
+					"address": [
+					{
+						"line1": "481 Harborsburgh",
+						"zip": "89799"
+					},
+					{
+						"line1": "329 Flatside",
+						"zip": "80872"
+					}
+					],
+					"phone": {
+					"personal": "7678456640",
+					"work": "8130097989"
+					}
+			}}`,
+			exp: `
+				CREATE SCHEMA test;
+				CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, date_of_birth VARCHAR(255), is_graduated BOOLEAN, name VARCHAR(255), roll_no FLOAT);
+				INSERT INTO test.student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
+				CREATE TABLE test.student_address (_id VARCHAR(255) PRIMARY KEY, line1 VARCHAR(255), student__id VARCHAR(255), zip VARCHAR(255));
+				INSERT INTO test.student_address (_id, line1, student__id, zip) VALUES ('14798c213f273a7ca2cf5174', '481 Harborsburgh', '635b79e231d82a8ab1de863b', '89799');
+				INSERT INTO test.student_address (_id, line1, student__id, zip) VALUES ('14798c213f273a7ca2cf5174', '329 Flatside', '635b79e231d82a8ab1de863b', '80872');
+				CREATE TABLE test.student_phone (_id VARCHAR(255) PRIMARY KEY, personal VARCHAR(255), student__id VARCHAR(255), work VARCHAR(255));
+				INSERT INTO test.student_phone (_id, personal, student__id, work) VALUES ('14798c213f273a7ca2cf5174', '7678456640', '635b79e231d82a8ab1de863b', '8130097989');
+			`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMockMongoOplogParser()
+			
+			got, err := m.GetEquivalentSQL(tc.input)
+			if err != nil {
+				t.Errorf("Error: %v", err)
+			}
+
+			result, err := compareSqlStatement(t, tc.exp, got)
+			if err != nil {
+				t.Fatalf("Error while comparing SQL statements: %v", err)
+			}
+
+			if !result {
+				t.Errorf("Expected %q but got %q", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestMongoOplogParserMalformed(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+	}{
+		{name: "null oplog", input: `null`},
+		{name: "scalar oplog", input: `42`},
+		{name: "array of scalars", input: `[1, 2]`},
+		{name: "missing op", input: `{"ns": "test.student", "o": {"_id": "1"}}`},
+		{name: "numeric op", input: `{"op": 1, "ns": "test.student", "o": {"_id": "1"}}`},
+		{name: "null ns", input: `{"op": "i", "ns": null, "o": {"_id": "1"}}`},
+		{name: "numeric ns", input: `{"op": "i", "ns": 1, "o": {"_id": "1"}}`},
+		{name: "ns without dot", input: `{"op": "i", "ns": "student", "o": {"_id": "1"}}`},
+		{name: "ns with empty collection", input: `{"op": "i", "ns": "test.", "o": {"_id": "1"}}`},
+		{name: "null o", input: `{"op": "i", "ns": "test.student", "o": null}`},
+		{name: "update without diff", input: `{"op": "u", "ns": "test.student", "o": {"$v": 2}, "o2": {"_id": "1"}}`},
+		{name: "update with scalar diff", input: `{"op": "u", "ns": "test.student", "o": {"diff": 1}, "o2": {"_id": "1"}}`},
+		{name: "update with scalar set", input: `{"op": "u", "ns": "test.student", "o": {"diff": {"u": 1}}, "o2": {"_id": "1"}}`},
+		{name: "update with scalar unset", input: `{"op": "u", "ns": "test.student", "o": {"diff": {"d": "x"}}, "o2": {"_id": "1"}}`},
+		{name: "update with scalar o2", input: `{"op": "u", "ns": "test.student", "o": {"diff": {"u": {"a": 1}}}, "o2": "1"}`},
+		{name: "update without o2", input: `{"op": "u", "ns": "test.student", "o": {"diff": {"u": {"a": 1}}}}`},
+		{name: "update with scalar insert", input: `{"op": "u", "ns": "test.student", "o": {"diff": {"i": 1}}, "o2": {"_id": "1"}}`},
+		{name: "update with subdiff", input: `{"op": "u", "ns": "test.student", "o": {"diff": {"u": {"a": 1}, "sphone": {"u": {"work": "1"}}}}, "o2": {"_id": "1"}}`},
+		{name: "delete with nested condition", input: `{"op": "d", "ns": "test.student", "o": {"_id": {"id": "1"}}}`},
+		{name: "insert with nested _id", input: `{"op": "i", "ns": "test.student", "o": {"_id": {"id": "1"}, "phone": {"work": "1"}}}`},
+		{name: "insert with array of scalars", input: `{"op": "i", "ns": "test.student", "o": {"_id": "1", "tags": ["a", "b"]}}`},
+		{name: "insert with deeply nested object", input: `{"op": "i", "ns": "test.student", "o": {"_id": "1", "phone": {"work": {"ext": "1"}}}}`},
+		{name: "prepared transaction", input: `{"op": "c", "ns": "admin.$cmd", "o": {"applyOps": [{"op": "d", "ns": "test.student", "o": {"_id": "1"}}], "prepare": true}}`},
+		{name: "scalar applyOps", input: `{"op": "c", "ns": "admin.$cmd", "o": {"applyOps": 1}}`},
+		{name: "transaction with command", input: `{"op": "c", "ns": "admin.$cmd", "o": {"applyOps": [{"op": "c", "ns": "test.$cmd", "o": {"drop": "student"}}]}}`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMockMongoOplogParser()
+
+			_, err := m.GetEquivalentSQL(tc.input)
+			if err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestMongoOplogParserNullAndNumericValues(t *testing.T) {
+	input := `{
+		"op": "i",
+		"ns": "test.student",
+		"o": {
+			"_id": 7,
+			"name": "Selena Miller",
+			"nickname": null,
+			"phone": {
+				"personal": 7678456640,
+				"work": null
+			}
+		}
+	}`
+	exp := `
+		CREATE SCHEMA test;
+		CREATE TABLE test.student (_id FLOAT PRIMARY KEY, name VARCHAR(255));
+		INSERT INTO test.student (_id, name) VALUES (7, 'Selena Miller');
+		CREATE TABLE test.student_phone (_id VARCHAR(255) PRIMARY KEY, personal FLOAT, student__id FLOAT);
+		INSERT INTO test.student_phone (_id, student__id, personal) VALUES ('14798c213f273a7ca2cf5174', 7, 7678456640);
+	`
+
+	m := NewMockMongoOplogParser()
+	got, err := m.GetEquivalentSQL(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := compareSqlStatement(t, exp, got)
+	if err != nil {
+		t.Fatalf("Error while comparing SQL statements: %v", err)
+	}
+
+	if !result {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+// all the columns are set and all the conditions are joined with AND, not just one of them
+func TestMongoOplogParserMultipleColumns(t *testing.T) {
+	input := `[
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 52, "is_graduated": true}, "d": {"phone": false, "address": false}}}, "o2": {"_id": "635b79e231d82a8ab1de863b"}},
+		{"op": "d", "ns": "test.student", "o": {"name": "Selena Miller", "roll_no": 52}}
+	]`
+	exp := []string{
+		"UPDATE test_student SET is_graduated = true, roll_no = 52, address = NULL, phone = NULL WHERE _id = '635b79e231d82a8ab1de863b';",
+		"DELETE FROM test_student WHERE name = 'Selena Miller' AND roll_no = 52;",
+	}
+
+	m := NewMockMongoOplogParser()
+	WithDialect(SQLite)(m)
+	got, err := m.GetEquivalentSQLStatements(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(exp, got) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+func TestMongoOplogParserQuotedValues(t *testing.T) {
+	input := `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "O'Brien"}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "'); DROP TABLE test_student; --"}}}, "o2": {"_id": "1"}},
+		{"op": "d", "ns": "test.student", "o": {"name": "O'Brien"}}
+	]`
+	exp := []string{
+		"INSERT INTO test_student (_id, name) VALUES ('1', 'O''Brien');",
+		"UPDATE test_student SET name = '''); DROP TABLE test_student; --' WHERE _id = '1';",
+		"DELETE FROM test_student WHERE name = 'O''Brien';",
+	}
+
+	m := NewMockMongoOplogParser()
+	WithDialect(SQLite)(m)
+	got, err := m.GetEquivalentSQLStatements(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != 4 || !slices.Equal(exp, got[1:]) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+func TestMongoOplogParserSchemaCache(t *testing.T) {
+	inputs := []string{
+		`{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller", "phone": {"work": "8130097989"}}}`,
+		`{"op": "i", "ns": "test.student", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith", "roll_no": 21, "phone": {"work": "7678456640", "personal": "8130097989"}}}`,
+		`{"op": "i", "ns": "test.teacher", "o": {"_id": "6d5f1f5c0e1d3a2b4c6e8f01", "name": "Nina Park"}}`,
+		`{"op": "i", "ns": "test.teacher", "o": {"_id": 1, "tags": ["a"]}}`,
+		`{"op": "i", "ns": "test.teacher", "o": {"_id": "6d5f1f5c0e1d3a2b4c6e8f02", "name": "Ravi Rao"}}`,
+	}
+	exps := []string{
+		`
+			CREATE SCHEMA test;
+			CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+			INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');
+			CREATE TABLE test.student_phone (_id VARCHAR(255) PRIMARY KEY, student__id VARCHAR(255), work VARCHAR(255));
+			INSERT INTO test.student_phone (_id, student__id, work) VALUES ('14798c213f273a7ca2cf5174', '635b79e231d82a8ab1de863b', '8130097989');
+		`,
+		`
+			ALTER TABLE test.student ADD roll_no FLOAT;
+			INSERT INTO test.student (_id, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', 'George Smith', 21);
+			ALTER TABLE test.student_phone ADD personal VARCHAR(255);
+			INSERT INTO test.student_phone (_id, student__id, personal, work) VALUES ('14798c213f273a7ca2cf5174', '14798c213f273a7ca2cf5174', '8130097989', '7678456640');
+		`,
+		`
+			CREATE TABLE test.teacher (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+			INSERT INTO test.teacher (_id, name) VALUES ('6d5f1f5c0e1d3a2b4c6e8f01', 'Nina Park');
+		`,
+		"",
+		"INSERT INTO test.teacher (_id, name) VALUES ('6d5f1f5c0e1d3a2b4c6e8f02', 'Ravi Rao');",
+	}
+
+	m := NewMockMongoOplogParser()
+	for i, input := range inputs {
+		got, err := m.GetEquivalentSQL(input)
+		if exps[i] == "" {
+			if err == nil {
+				t.Errorf("Expected error for oplog %d but got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error for oplog %d: %v", i, err)
+		}
+
+		result, err := compareSqlStatement(t, exps[i], got)
+		if err != nil {
+			t.Fatalf("Error while comparing SQL statements: %v", err)
+		}
+
+		if !result {
+			t.Errorf("Expected %q but got %q", exps[i], got)
+		}
+	}
+}
+
+func TestGetSchemaStatements(t *testing.T) {
+	inputs := []string{
+		`{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller", "phone": {"work": "8130097989"}}}`,
+		`{"op": "i", "ns": "test.student", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith", "roll_no": 21}}`,
+		`{"op": "i", "ns": "school.teacher", "o": {"_id": "6d5f1f5c0e1d3a2b4c6e8f01", "name": "Nina Park"}}`,
+	}
+	exp := `
+		CREATE SCHEMA school;
+		CREATE TABLE school.teacher (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+		CREATE SCHEMA test;
+		CREATE TABLE test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255), roll_no FLOAT);
+		CREATE TABLE test.student_phone (_id VARCHAR(255) PRIMARY KEY, student__id VARCHAR(255), work VARCHAR(255));
+	`
+
+	m := NewMockMongoOplogParser()
+	for _, input := range inputs {
+		if _, err := m.GetEquivalentSQL(input); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	got := strings.Join(m.GetSchemaStatements(), "")
+
+	result, err := compareSqlStatement(t, exp, got)
+	if err != nil {
+		t.Fatalf("Error while comparing SQL statements: %v", err)
+	}
+
+	if !result {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+func TestMongoOplogParserSQLite(t *testing.T) {
+	input := `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller", "phone": {"work": "8130097989"}}},
+		{"op": "i", "ns": "test.student", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith", "roll_no": 21}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 22}}}, "o2": {"_id": "14798c213f273a7ca2cf5174"}},
+		{"op": "d", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b"}}
+	]`
+	exp := []string{
+		"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+		"INSERT INTO test_student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');",
+		"CREATE TABLE test_student_phone (_id  VARCHAR(255) PRIMARY KEY, student__id  VARCHAR(255), work  VARCHAR(255));",
+		"INSERT INTO test_student_phone (_id, student__id, work) VALUES ('14798c213f273a7ca2cf5174', '635b79e231d82a8ab1de863b', '8130097989');",
+		"ALTER TABLE test_student ADD COLUMN roll_no  FLOAT;",
+		"INSERT INTO test_student (_id, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', 'George Smith', 21);",
+		"UPDATE test_student SET roll_no = 22 WHERE _id = '14798c213f273a7ca2cf5174';",
+		"DELETE FROM test_student WHERE _id = '635b79e231d82a8ab1de863b';",
+	}
+
+	m := NewMockMongoOplogParser()
+	WithDialect(SQLite)(m)
+
+	got, err := m.GetEquivalentSQLStatements(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !slices.Equal(exp, got) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+func TestMongoOplogParserExtendedJSON(t *testing.T) {
+	input := `[
+		{"op": "i", "ns": "test.student", "ts": {"$timestamp": {"t": 1700000000, "i": 1}}, "o": {"_id": {"$oid": "635b79e231d82a8ab1de863b"}, "name": "Selena Miller", "roll_no": {"$numberLong": "51"}, "joined": {"$date": {"$numberLong": "1666938000000"}}}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": {"$numberInt": "52"}}}}, "o2": {"_id": {"$oid": "635b79e231d82a8ab1de863b"}}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": {"$numberLong": "9007199254740993"}}}}, "o2": {"_id": {"$oid": "635b79e231d82a8ab1de863b"}}},
+		{"op": "d", "ns": "test.student", "o": {"_id": {"$oid": "635b79e231d82a8ab1de863b"}}}
+	]`
+	exp := []string{
+		"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, joined  VARCHAR(255), name  VARCHAR(255), roll_no  FLOAT);",
+		"INSERT INTO test_student (_id, joined, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2022-10-28T06:20:00.000Z', 'Selena Miller', 51);",
+		"UPDATE test_student SET roll_no = 52 WHERE _id = '635b79e231d82a8ab1de863b';",
+		"UPDATE test_student SET roll_no = 9007199254740993 WHERE _id = '635b79e231d82a8ab1de863b';",
+		"DELETE FROM test_student WHERE _id = '635b79e231d82a8ab1de863b';",
+	}
+
+	m := NewMockMongoOplogParser()
+	WithDialect(SQLite)(m)
+
+	got, err := m.GetEquivalentSQLStatements(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !slices.Equal(exp, got) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
+
+func TestMongoOplogParserDottedNamespaces(t *testing.T) {
+	input := `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}},
+		{"op": "i", "ns": "test.student.archive", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith", "home-phone": {"work": "8130097989"}}},
+		{"op": "d", "ns": "test.student.archive", "o": {"_id": "14798c213f273a7ca2cf5174"}},
+		{"op": "i", "ns": "test.2024-results", "o": {"_id": "635b79e231d82a8ab1de863b", "score": 91}}
+	]`
+	exp := []string{
+		"CREATE SCHEMA test;",
+		"CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+		"INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');",
+		"CREATE TABLE test.student_archive (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+		"INSERT INTO test.student_archive (_id, name) VALUES ('14798c213f273a7ca2cf5174', 'George Smith');",
+		"CREATE TABLE test.student_archive_home_phone (_id  VARCHAR(255) PRIMARY KEY, student_archive__id  VARCHAR(255), work  VARCHAR(255));",
+		"INSERT INTO test.student_archive_home_phone (_id, student_archive__id, work) VALUES ('14798c213f273a7ca2cf5174', '14798c213f273a7ca2cf5174', '8130097989');",
+		"DELETE FROM test.student_archive WHERE _id = '14798c213f273a7ca2cf5174';",
+		"CREATE TABLE test._2024_results (_id  VARCHAR(255) PRIMARY KEY, score  FLOAT);",
+		"INSERT INTO test._2024_results (_id, score) VALUES ('635b79e231d82a8ab1de863b', 91);",
+	}
+
+	m := NewMockMongoOplogParser()
+	got, err := m.GetEquivalentSQLStatements(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(exp, got) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+
+	expNames := map[string]string{
+		"test.student": "test.student",
+		"test.student_archive": "test.student.archive",
+		"test._2024_results": "test.2024-results",
+	}
+	if !maps.Equal(expNames, m.GetTableNames()) {
+		t.Errorf("Expected %q but got %q", expNames, m.GetTableNames())
+	}
+
+	// rows of a collection which sanitizes to an existing table are not merged into it
+	_, err = m.GetEquivalentSQL(`{"op": "i", "ns": "test.student_archive", "o": {"_id": "1", "name": "Jane Doe"}}`)
+	if err == nil {
+		t.Errorf("Expected error but got nil")
+	}
+}
+
+// run with -race to check the shared tables for data races
+func TestMongoOplogParserConcurrent(t *testing.T) {
+	const goroutines = 8
+	const oplogs = 50
+
+	m := NewMongoOplogParser()
+	var mu sync.Mutex
+	var all []string
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < oplogs; i++ {
+				input := fmt.Sprintf(`{"op": "i", "ns": "test.coll_%d", "o": {"_id": "%d_%d", "name": "Selena Miller", "field_%d": %d, "phone": {"work": "8130097989"}}}`, i%3, g, i, g, i)
+				stmts, err := m.GetEquivalentSQLStatements(input)
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				m.GetSchemaStatements()
+				m.GetTableNames()
+
+				mu.Lock()
+				all = append(all, stmts...)
+				mu.Unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// each schema, table and column is created exactly once, whichever goroutine gets to it first
+	counts := make(map[string]int)
+	for _, stmt := range all {
+		if !strings.HasPrefix(stmt, "INSERT") {
+			counts[stmt]++
+		}
+	}
+	for stmt, count := range counts {
+		if count != 1 {
+			t.Errorf("Expected %q once but got %d times", stmt, count)
+		}
+	}
+
+	expDDL := 1 + 3*2 + 3*(goroutines-1)
+	if len(counts) != expDDL {
+		t.Errorf("Expected %d ddl statements but got %d: %q", expDDL, len(counts), counts)
+	}
+	if got := len(all) - expDDL; got != goroutines*oplogs*2 {
+		t.Errorf("Expected %d insert statements but got %d", goroutines*oplogs*2, got)
+	}
+}
+
+func TestGetEquivalentSQLContext(t *testing.T) {
+	input := `{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}}`
+	m := NewMockMongoOplogParser()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.GetEquivalentSQLContext(ctx, input); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context canceled error but got %v", err)
+	}
+
+	// nothing is remembered from the canceled call
+	got, err := m.GetEquivalentSQLStatementsContext(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("Expected schema, table and insert statements but got %q", got)
+	}
+}
+
+func TestMongoOplogParserIdempotent(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		exp string
+	}{
+		{
+			name: "create table with insert statement",
+			input: `{
+				"op": "i",
+				"ns": "test.student",
+				"o": {
+					"_id": "635b79e231d82a8ab1de863b",
+					"name": "Selena Miller",
+					"roll_no": 51
+				}
+			}`,
+			exp: `
+				CREATE SCHEMA IF NOT EXISTS test;
+				CREATE TABLE IF NOT EXISTS test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255), roll_no FLOAT);
+				INSERT INTO test.student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 51)
+					ON CONFLICT (_id) DO UPDATE SET name = EXCLUDED.name, roll_no = EXCLUDED.roll_no;
+			`,
+		},
+		{
+			name: "insert statement with only _id",
+			input: `{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b"}}`,
+			exp: `
+				CREATE SCHEMA IF NOT EXISTS test;
+				CREATE TABLE IF NOT EXISTS test.student (_id VARCHAR(255) PRIMARY KEY);
+				INSERT INTO test.student (_id) VALUES ('635b79e231d82a8ab1de863b') ON CONFLICT (_id) DO NOTHING;
+			`,
+		},
+		{
+			name: "alter table with multiple insert statement",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "635b79e231d82a8ab1de863b", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "14798c213f273a7ca2cf5174", "name": "George Smith", "phone": "+91-81254966457"}}
+			]`,
+			exp: `
+				CREATE SCHEMA IF NOT EXISTS test;
+				CREATE TABLE IF NOT EXISTS test.student (_id VARCHAR(255) PRIMARY KEY, name VARCHAR(255));
+				INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller')
+					ON CONFLICT (_id) DO UPDATE SET name = EXCLUDED.name;
+				ALTER TABLE test.student ADD COLUMN IF NOT EXISTS phone VARCHAR(255);
+				INSERT INTO test.student (_id, name, phone) VALUES ('14798c213f273a7ca2cf5174', 'George Smith', '+91-81254966457')
+					ON CONFLICT (_id) DO UPDATE SET name = EXCLUDED.name, phone = EXCLUDED.phone;
+			`,
+		},
+		{
+			name: "handling nested objects",
+			input: `{
+				"op": "i",
+				"ns": "test.student",
+				"o": {
+					"_id": "635b79e231d82a8ab1de863b",
+					"phone": {
+						"personal": "7678456640"
+					}
+				}
+			}`,
+			exp: `
+				CREATE SCHEMA IF NOT EXISTS test;
+				CREATE TABLE IF NOT EXISTS test.student (_id VARCHAR(255) PRIMARY KEY);
+				INSERT INTO test.student (_id) VALUES ('635b79e231d82a8ab1de863b') ON CONFLICT (_id) DO NOTHING;
+				CREATE TABLE IF NOT EXISTS test.student_phone (_id VARCHAR(255) PRIMARY KEY, personal VARCHAR(255), student__id VARCHAR(255));
+				INSERT INTO test.student_phone (_id, student__id, personal) VALUES ('c4ad6ab5a2a4ee3b4b3a07a5', '635b79e231d82a8ab1de863b', '7678456640')
+					ON CONFLICT (_id) DO UPDATE SET student__id = EXCLUDED.student__id, personal = EXCLUDED.personal;
+			`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMongoOplogParser(WithIdempotent())
+
+			got, err := m.GetEquivalentSQL(tc.input)
+			if err != nil {
+				t.Errorf("Error: %v", err)
+			}
+
+			result, err := compareSqlStatement(t, tc.exp, got)
+			if err != nil {
+				t.Fatalf("Error while comparing SQL statements: %v", err)
+			}
+
+			if !result {
+				t.Errorf("Expected %q but got %q", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestPreparedOplogTimestamp(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		expTs primitive.Timestamp
+		expOk bool
+	}{
+		{name: "extended json ts", input: `{"ts": {"$timestamp": {"t": 1700000000, "i": 2}}, "op": "i"}`, expTs: primitive.Timestamp{T: 1700000000, I: 2}, expOk: true},
+		{name: "plain ts", input: `{"ts": {"t": 1700000000, "i": 2}, "op": "i"}`, expTs: primitive.Timestamp{T: 1700000000, I: 2}, expOk: true},
+		{name: "change event cluster time", input: `{"clusterTime": {"$timestamp": {"t": 1700000000, "i": 3}}, "operationType": "insert"}`, expTs: primitive.Timestamp{T: 1700000000, I: 3}, expOk: true},
+		{name: "last ts of array", input: `[{"ts": {"t": 1, "i": 1}}, {"ts": {"t": 2, "i": 1}}]`, expTs: primitive.Timestamp{T: 2, I: 1}, expOk: true},
+		{name: "without ts", input: `{"op": "i"}`},
+		{name: "numeric ts", input: `{"ts": 1700000000}`},
+		{name: "negative ts", input: `{"ts": {"t": -1, "i": 1}}`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, ok := NewMockMongoOplogParser().Prepare(tc.input).Timestamp()
+			if ok != tc.expOk || !ts.Equal(tc.expTs) {
+				t.Errorf("Expected %v, %v but got %v, %v", tc.expTs, tc.expOk, ts, ok)
+			}
+		})
+	}
+}
+
+func TestForeignRowIdIdempotent(t *testing.T) {
+	s := &MongoOplog{dbName: "test", tableName: "student", idempotent: true}
+
+	first := s.getForeignRowId("address", "635b79e231d82a8ab1de863b", 0)
+	if again := s.getForeignRowId("address", "635b79e231d82a8ab1de863b", 0); first != again {
+		t.Errorf("Expected same id on replay but got %q and %q", first, again)
+	}
+	if other := s.getForeignRowId("address", "635b79e231d82a8ab1de863b", 1); first == other {
+		t.Errorf("Expected different id for different row but got %q for both", first)
+	}
+	if len(first) != 24 {
+		t.Errorf("Expected id of length 24 but got %q", first)
+	}
+}
+
+// makes sure that no input can crash the parser
+// seed corpus is taken from the oplogs in testdata
+func FuzzGetEquivalentSQL(f *testing.F) {
+	data, err := os.ReadFile("../testdata/oplog.json")
+	if err != nil {
+		f.Fatalf("Error while reading seed corpus: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var obj json.RawMessage
+		if err := decoder.Decode(&obj); err != nil {
+			if err == io.EOF {
+				break
+			}
+			f.Fatalf("Error while decoding seed corpus: %v", err)
+		}
+		f.Add(string(obj))
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		m := NewMockMongoOplogParser()
+		m.GetEquivalentSQL(input)
+	})
+}
+
+// compares if sql statements are equal on the basis of fingerprint
+// if they are equivalent, fingerprint will be same
+func compareSqlStatement(t *testing.T, expected, got string) (bool, error) {
+	t.Helper()
+
+	expFp, err := pgquery.Fingerprint(expected)
+	if err != nil {
+		return false, err
+	}
+
+	gotFp, err := pgquery.Fingerprint(got)
+	if err != nil {
+		return false, err
+	}
+
+	return expFp == gotFp, nil
+}
@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StatementKind tells what a Statement does
+type StatementKind int
+
+const (
+	// DDL creates a schema or a table, or adds a column to a table
+	DDL StatementKind = iota
+	Insert
+	Update
+	Delete
+)
+
+func (k StatementKind) String() string {
+	switch k {
+	case DDL:
+		return "ddl"
+	case Insert:
+		return "insert"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	default:
+		return fmt.Sprintf("StatementKind(%d)", int(k))
+	}
+}
+
+// Statement is a sql statement along with the table it is about, for the callers
+// which need more than the sql, i.e. to merge the inserts into the same table.
+// TableName is empty for the statements creating a schema.
+type Statement struct {
+	Kind StatementKind
+	DBName string
+	TableName string
+	// Columns and Rows of an insert, values are the plain json values, i.e. string, float64
+	// or bool, or int64 for the $numberInt and $numberLong of extended json
+	// Types are the sql types of the columns as per the values of the first row, i.e. FLOAT
+	// For the ddl, Columns are the ones created or added to the table, along with their Types
+	Columns []string
+	Types []string
+	Rows [][]interface{}
+	// Set and Unset columns of an update, and the Where condition of an update or a delete,
+	// which is the equality of all the given columns and values
+	Set map[string]interface{}
+	Unset []string
+	Where map[string]interface{}
+	// Ts of the oplog the statement is for, HasTs is false if the oplog has none or for the ddl
+	Ts primitive.Timestamp
+	HasTs bool
+	SQL string
+}
+
+// WithInsertBatching makes CoalesceInserts merge up to the given number of rows
+// into a single insert statement, default is 1, i.e. inserts are left as they are
+func WithInsertBatching(maxRows int) Option {
+	return func(m *MongoOplogParser) {
+		m.insertBatchRows = maxRows
+	}
+}
+
+// CoalesceInserts merges the consecutive inserts into the same table with the same
+// columns into multi-row inserts, as per WithInsertBatching. Any other statement, or
+// an insert into another table or with other columns, ends the insert being merged,
+// so the statements still run in the same order. In idempotent mode, rows with the
+// same _id are not merged, as an upsert can't touch the same row twice.
+func(m *MongoOplogParser) CoalesceInserts(stmts []Statement) []Statement {
+	if m.insertBatchRows <= 1 {
+		return stmts
+	}
+
+	var result []Statement
+	var pending *Statement
+	var pendingIds map[interface{}]bool
+
+	flush := func() {
+		if pending == nil {
+			return
+		}
+		if len(pending.Rows) > 1 {
+			s := &MongoOplog{dbName: pending.DBName, idempotent: m.idempotent, dialect: m.dialect}
+			pending.SQL = s.getInsertStatement(pending.TableName, pending.Columns, pending.Rows)
+		}
+		result = append(result, *pending)
+		pending = nil
+	}
+
+	for _, stmt := range stmts {
+		if stmt.Kind != Insert {
+			flush()
+			result = append(result, stmt)
+			continue
+		}
+
+		idIndex := slices.Index(stmt.Columns, idKey)
+		if pending != nil && m.canCoalesce(pending, &stmt, pendingIds, idIndex) {
+			for _, row := range stmt.Rows {
+				pending.Rows = append(pending.Rows, row)
+				if idIndex >= 0 {
+					pendingIds[row[idIndex]] = true
+				}
+			}
+			pending.Ts, pending.HasTs = stmt.Ts, stmt.HasTs
+			continue
+		}
+
+		flush()
+		pending = &Statement{
+			Kind: Insert,
+			DBName: stmt.DBName,
+			TableName: stmt.TableName,
+			Columns: stmt.Columns,
+			Types: stmt.Types,
+			Rows: slices.Clone(stmt.Rows),
+			Ts: stmt.Ts,
+			HasTs: stmt.HasTs,
+			SQL: stmt.SQL,
+		}
+		pendingIds = make(map[interface{}]bool)
+		for _, row := range stmt.Rows {
+			if idIndex >= 0 {
+				pendingIds[row[idIndex]] = true
+			}
+		}
+	}
+	flush()
+
+	return result
+}
+
+// checks if the rows of the insert can be added to the pending one
+func(m *MongoOplogParser) canCoalesce(pending, stmt *Statement, pendingIds map[interface{}]bool, idIndex int) bool {
+	if pending.DBName != stmt.DBName || pending.TableName != stmt.TableName || !slices.Equal(pending.Columns, stmt.Columns) {
+		return false
+	}
+	if len(pending.Rows) + len(stmt.Rows) > m.insertBatchRows {
+		return false
+	}
+	if m.idempotent && idIndex >= 0 {
+		for _, row := range stmt.Rows {
+			if pendingIds[row[idIndex]] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GetSQL returns the sql of the statements, in the same order
+func GetSQL(stmts []Statement) []string {
+	var sqlStmts []string
+	for _, stmt := range stmts {
+		sqlStmts = append(sqlStmts, stmt.SQL)
+	}
+	return sqlStmts
+}
@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCoalesceInserts(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		maxRows int
+		idempotent bool
+		exp []string
+	}{
+		{
+			name: "consecutive inserts with same columns",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe"}}
+			]`,
+			maxRows: 10,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Miller'), ('2', 'George Smith'), ('3', 'Jane Doe');",
+			},
+		},
+		{
+			name: "limited to max rows",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe"}}
+			]`,
+			maxRows: 2,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Miller'), ('2', 'George Smith');",
+				"INSERT INTO test_student (_id, name) VALUES ('3', 'Jane Doe');",
+			},
+		},
+		{
+			name: "different columns, ddl and other statements end the batch",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "roll_no": 51}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "3", "roll_no": 52}},
+				{"op": "d", "ns": "test.student", "o": {"_id": "1"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "4", "roll_no": 53}}
+			]`,
+			maxRows: 10,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Miller');",
+				"ALTER TABLE test_student ADD COLUMN roll_no  FLOAT;",
+				"INSERT INTO test_student (_id, roll_no) VALUES ('2', 51), ('3', 52);",
+				"DELETE FROM test_student WHERE _id = '1';",
+				"INSERT INTO test_student (_id, roll_no) VALUES ('4', 53);",
+			},
+		},
+		{
+			name: "nested object tables in between",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "address": {"zip": "89799"}}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}},
+				{"op": "i", "ns": "test.teacher", "o": {"_id": "3", "name": "Jane Doe"}}
+			]`,
+			maxRows: 10,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Miller');",
+				"CREATE TABLE test_student_address (_id  VARCHAR(255) PRIMARY KEY, student__id  VARCHAR(255), zip  VARCHAR(255));",
+				"INSERT INTO test_student_address (_id, student__id, zip) VALUES ('14798c213f273a7ca2cf5174', '1', '89799');",
+				"INSERT INTO test_student (_id, name) VALUES ('2', 'George Smith');",
+				"CREATE TABLE test_teacher (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_teacher (_id, name) VALUES ('3', 'Jane Doe');",
+			},
+		},
+		{
+			name: "upserts of the same _id are not merged",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Smith"}}
+			]`,
+			maxRows: 10,
+			idempotent: true,
+			exp: []string{
+				"CREATE TABLE IF NOT EXISTS test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Miller'), ('2', 'George Smith') ON CONFLICT (_id) DO UPDATE SET name = EXCLUDED.name;",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Smith') ON CONFLICT (_id) DO UPDATE SET name = EXCLUDED.name;",
+			},
+		},
+		{
+			name: "batching disabled",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}}
+			]`,
+			exp: []string{
+				"CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));",
+				"INSERT INTO test_student (_id, name) VALUES ('1', 'Selena Miller');",
+				"INSERT INTO test_student (_id, name) VALUES ('2', 'George Smith');",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMockMongoOplogParser()
+			WithDialect(SQLite)(m)
+			WithInsertBatching(tc.maxRows)(m)
+			if tc.idempotent {
+				WithIdempotent()(m)
+			}
+
+			stmts, err := m.ResolveStatements(m.Prepare(tc.input))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := GetSQL(m.CoalesceInserts(stmts))
+			if !slices.Equal(tc.exp, got) {
+				t.Errorf("Expected %q but got %q", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestResolveStatements(t *testing.T) {
+	m := NewMockMongoOplogParser()
+	stmts, err := m.ResolveStatements(m.Prepare(`{"op": "i", "ns": "test.student", "o": {"_id": "1", "roll_no": 51, "is_graduated": false}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	kinds := make([]StatementKind, 0, len(stmts))
+	for _, stmt := range stmts {
+		kinds = append(kinds, stmt.Kind)
+	}
+	if exp := []StatementKind{DDL, DDL, Insert}; !slices.Equal(exp, kinds) {
+		t.Fatalf("Expected %v but got %v", exp, kinds)
+	}
+
+	if stmts[0].TableName != "" || len(stmts[0].Columns) != 0 {
+		t.Errorf("Expected no table for the schema but got %s with %q", stmts[0].TableName, stmts[0].Columns)
+	}
+	table := stmts[1]
+	if exp := []string{"_id", "is_graduated", "roll_no"}; table.TableName != "student" || !slices.Equal(exp, table.Columns) {
+		t.Errorf("Expected table student with columns %q but got %s with %q", exp, table.TableName, table.Columns)
+	}
+	if exp := []string{"VARCHAR(255)", "BOOLEAN", "FLOAT"}; !slices.Equal(exp, table.Types) {
+		t.Errorf("Expected types %q but got %q", exp, table.Types)
+	}
+
+	insert := stmts[2]
+	if insert.DBName != "test" || insert.TableName != "student" {
+		t.Errorf("Expected table test.student but got %s.%s", insert.DBName, insert.TableName)
+	}
+	if exp := []string{"_id", "is_graduated", "roll_no"}; !slices.Equal(exp, insert.Columns) {
+		t.Errorf("Expected columns %q but got %q", exp, insert.Columns)
+	}
+	if exp := []string{"VARCHAR(255)", "BOOLEAN", "FLOAT"}; !slices.Equal(exp, insert.Types) {
+		t.Errorf("Expected types %q but got %q", exp, insert.Types)
+	}
+	if exp := []interface{}{"1", false, float64(51)}; len(insert.Rows) != 1 || !slices.Equal(exp, insert.Rows[0]) {
+		t.Errorf("Expected rows %v but got %v", exp, insert.Rows)
+	}
+}
+
+func TestStatementTimestamp(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		expTs primitive.Timestamp
+		expHasTs bool
+	}{
+		{
+			name: "oplog ts",
+			input: `{"op": "d", "ns": "test.student", "ts": {"$timestamp": {"t": 1700000000, "i": 2}}, "o": {"_id": "1"}}`,
+			expTs: primitive.Timestamp{T: 1700000000, I: 2},
+			expHasTs: true,
+		},
+		{
+			name: "change event clusterTime",
+			input: `{"operationType": "delete", "clusterTime": {"$timestamp": {"t": 1700000000, "i": 3}}, "ns": {"db": "test", "coll": "student"}, "documentKey": {"_id": "1"}}`,
+			expTs: primitive.Timestamp{T: 1700000000, I: 3},
+			expHasTs: true,
+		},
+		{
+			name: "no ts",
+			input: `{"op": "d", "ns": "test.student", "o": {"_id": "1"}}`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMockMongoOplogParser()
+			stmts, err := m.ResolveStatements(m.Prepare(tc.input))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(stmts) != 1 {
+				t.Fatalf("Expected a single statement but got %d", len(stmts))
+			}
+			if stmts[0].Ts != tc.expTs || stmts[0].HasTs != tc.expHasTs {
+				t.Errorf("Expected ts %v (%v) but got %v (%v)", tc.expTs, tc.expHasTs, stmts[0].Ts, stmts[0].HasTs)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// writes of a multi-document transaction are logged as a single command oplog,
+// {"op": "c", "o": {"applyOps": [...]}}, holding the oplogs of the writes
+func isApplyOps(oplog map[string]interface{}) bool {
+	if oplog["op"] != "c" {
+		return false
+	}
+	o, ok := oplog["o"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = o["applyOps"]
+	return ok
+}
+
+// returns the oplogs of the writes held by the applyOps oplog, each with the ts of the
+// transaction. Collections created within the transaction are left out, as the tables are
+// created on the first insert anyway, and the other commands are left to fail while parsing.
+// A prepared transaction is committed, or aborted, by a later oplog, so it is not supported.
+func applyOpsToOplogs(oplog map[string]interface{}) ([]map[string]interface{}, error) {
+	o := oplog["o"].(map[string]interface{})
+	if prepare, _ := o["prepare"].(bool); prepare {
+		return nil, fmt.Errorf("error: unsupported prepared transaction: failed to apply its writes")
+	}
+	ops, ok := o["applyOps"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error: o.applyOps is not a json array: failed to apply the writes of the transaction")
+	}
+
+	oplogs := make([]map[string]interface{}, 0, len(ops))
+	for i, op := range ops {
+		opMap, ok := op.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error: o.applyOps entry at index %d is not a json object", i)
+		}
+		if cmd, ok := opMap["o"].(map[string]interface{}); ok && opMap["op"] == "c" && cmd["create"] != nil {
+			continue
+		}
+
+		if ts, ok := oplog["ts"]; ok {
+			opMap["ts"] = ts
+		}
+		oplogs = append(oplogs, opMap)
+	}
+	return oplogs, nil
+}
@@ -0,0 +1,393 @@
+// Package replay applies the statements of the parser to tables kept in memory, to tell the
+// rows the generated sql leaves behind in a database without having to run one.
+package replay
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+)
+
+const idKey = "_id"
+
+// Engine keeps the tables the statements were applied to, keyed by <db>.<table>.
+// Statements are applied the way a database would run their sql, i.e. inserting a
+// row with an _id already there, or using a column not created by the ddl, fails.
+// Where conditions only match the values of the same type, and never match null.
+// Schemas aren't kept, as sqlite has none, so tables are created without them.
+type Engine struct {
+	tables map[string]*Table
+	idempotent bool
+	lenient bool
+}
+
+// Option configures the behaviour of Engine
+type Option func(*Engine)
+
+// WithIdempotent makes the engine apply the statements the way the parser makes them
+// with parser.WithIdempotent, i.e. inserts are upserts on _id, and ddl does nothing
+// for the columns already there.
+func WithIdempotent() Option {
+	return func(e *Engine) {
+		e.idempotent = true
+	}
+}
+
+// WithLenient makes the engine apply the statements which would fail in a database as far
+// as it can, for the callers which want the rows rather than the errors. Tables and columns
+// are created as they are used, and inserting a row already there replaces it.
+func WithLenient() Option {
+	return func(e *Engine) {
+		e.lenient = true
+	}
+}
+
+func NewEngine(opts ...Option) *Engine {
+	e := &Engine{tables: make(map[string]*Table)}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Row is a row of a table keyed by column, null columns are left out
+type Row map[string]interface{}
+
+// Table is a table along with its rows, which are kept in the order they were inserted in
+type Table struct {
+	DBName string
+	TableName string
+	types map[string]string		// sql types of the columns, i.e. FLOAT
+	rows map[interface{}]*entry	// rows keyed by _id
+	seq int						// number of rows inserted so far
+}
+
+type entry struct {
+	seq int
+	row Row
+}
+
+// key for the rows inserted without _id
+type noIdKey int
+
+// Apply applies the statements in order, stopping at the first one which fails.
+// The failed statement is left out as a whole, like a database would.
+func(e *Engine) Apply(stmts ...parser.Statement) error {
+	for i := range stmts {
+		if err := e.apply(&stmts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func(e *Engine) apply(stmt *parser.Statement) error {
+	// schemas aren't kept
+	if stmt.TableName == "" {
+		return nil
+	}
+
+	name := stmt.DBName + "." + stmt.TableName
+	t, ok := e.tables[name]
+	if !ok {
+		if stmt.Kind != parser.DDL && !e.lenient {
+			return fmt.Errorf("error: no such table %s", name)
+		}
+		t = &Table{DBName: stmt.DBName, TableName: stmt.TableName, types: make(map[string]string), rows: make(map[interface{}]*entry)}
+		e.tables[name] = t
+	}
+
+	switch stmt.Kind {
+	case parser.DDL:
+		for _, col := range stmt.Columns {
+			if _, ok := t.types[col]; ok && !e.idempotent && !e.lenient {
+				return fmt.Errorf("error: column %s already exists in table %s", col, name)
+			}
+		}
+		for i, col := range stmt.Columns {
+			if _, ok := t.types[col]; !ok {
+				t.types[col] = getType(stmt.Types, i)
+			}
+		}
+		return nil
+	case parser.Insert:
+		return e.insert(t, stmt)
+	case parser.Update:
+		cols := parser.GetSortedKeys(stmt.Set)
+		cols = append(cols, stmt.Unset...)
+		cols = append(cols, parser.GetSortedKeys(stmt.Where)...)
+		if err := e.checkColumns(t, cols, nil); err != nil {
+			return err
+		}
+		return t.update(stmt.Set, stmt.Unset, stmt.Where)
+	case parser.Delete:
+		if err := e.checkColumns(t, parser.GetSortedKeys(stmt.Where), nil); err != nil {
+			return err
+		}
+		for _, key := range t.match(stmt.Where) {
+			delete(t.rows, key)
+		}
+		return nil
+	default:
+		return fmt.Errorf("error: unknown statement kind %v", stmt.Kind)
+	}
+}
+
+// checks that the table has the columns, adding them in lenient mode
+func(e *Engine) checkColumns(t *Table, cols []string, types []string) error {
+	for i, col := range cols {
+		if _, ok := t.types[col]; ok {
+			continue
+		}
+		if !e.lenient {
+			return fmt.Errorf("error: no such column %s in table %s.%s", col, t.DBName, t.TableName)
+		}
+		t.types[col] = getType(types, i)
+	}
+	return nil
+}
+
+func(e *Engine) insert(t *Table, stmt *parser.Statement) error {
+	if err := e.checkColumns(t, stmt.Columns, stmt.Types); err != nil {
+		return err
+	}
+
+	rows := make([]Row, 0, len(stmt.Rows))
+	for _, vals := range stmt.Rows {
+		row := make(Row, len(vals))
+		for i, col := range stmt.Columns {
+			if vals[i] != nil {
+				row[col] = vals[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	// none of the rows is inserted if any of them is already there
+	if !e.idempotent && !e.lenient {
+		ids := make(map[interface{}]bool, len(rows))
+		for _, row := range rows {
+			id, ok := row[idKey]
+			if !ok {
+				continue
+			}
+			if _, exists := t.rows[id]; exists || ids[id] {
+				return fmt.Errorf("error: duplicate _id %v in table %s.%s", id, t.DBName, t.TableName)
+			}
+			ids[id] = true
+		}
+	}
+
+	for _, row := range rows {
+		id, ok := row[idKey]
+		if !ok {
+			t.seq++
+			t.rows[noIdKey(t.seq)] = &entry{seq: t.seq, row: row}
+			continue
+		}
+
+		if existing, ok := t.rows[id]; ok && e.idempotent {
+			// upsert sets the inserted columns, the rest of the row stays as it is
+			for _, col := range stmt.Columns {
+				if val, ok := row[col]; ok {
+					existing.row[col] = val
+				} else {
+					delete(existing.row, col)
+				}
+			}
+			continue
+		}
+		if existing, ok := t.rows[id]; ok {
+			existing.row = row
+			continue
+		}
+		t.seq++
+		t.rows[id] = &entry{seq: t.seq, row: row}
+	}
+	return nil
+}
+
+func(t *Table) update(set map[string]interface{}, unset []string, where map[string]interface{}) error {
+	for _, key := range t.match(where) {
+		e := t.rows[key]
+		for col, val := range set {
+			if val == nil {
+				delete(e.row, col)
+			} else {
+				e.row[col] = val
+			}
+		}
+		for _, col := range unset {
+			delete(e.row, col)
+		}
+
+		// rows are keyed by _id, so the row moves along if it is updated
+		if id, ok := e.row[idKey]; ok && id != key {
+			if _, exists := t.rows[id]; exists {
+				return fmt.Errorf("error: duplicate _id %v in table %s.%s", id, t.DBName, t.TableName)
+			}
+			delete(t.rows, key)
+			t.rows[id] = e
+		}
+	}
+	return nil
+}
+
+// returns the keys of the rows having all the given values, looked up by _id if it is among them
+func(t *Table) match(where map[string]interface{}) []interface{} {
+	if id, ok := where[idKey]; ok {
+		if e, ok := t.rows[id]; ok && matches(e.row, where) {
+			return []interface{}{id}
+		}
+		return nil
+	}
+
+	var keys []interface{}
+	for key, e := range t.rows {
+		if matches(e.row, where) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// null is never equal to anything, like in sql
+func matches(row Row, where map[string]interface{}) bool {
+	for col, val := range where {
+		rowVal, ok := row[col]
+		if !ok || val == nil || rowVal != val {
+			return false
+		}
+	}
+	return true
+}
+
+// Tables returns the tables in sorted order of <db>.<table>
+func(e *Engine) Tables() []*Table {
+	names := parser.GetSortedKeys(e.tables)
+	tables := make([]*Table, 0, len(names))
+	for _, name := range names {
+		tables = append(tables, e.tables[name])
+	}
+	return tables
+}
+
+// Table returns the table, or nil if there is no such table
+func(e *Engine) Table(dbName, tableName string) *Table {
+	return e.tables[dbName + "." + tableName]
+}
+
+// Columns returns the columns of the table in sorted order
+func(t *Table) Columns() []string {
+	return parser.GetSortedKeys(t.types)
+}
+
+// ColumnType returns the sql type of the column as given by the ddl, i.e. FLOAT,
+// or an empty string if the column was created without one in lenient mode
+func(t *Table) ColumnType(col string) string {
+	return t.types[col]
+}
+
+// Len returns the number of rows in the table
+func(t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Get returns a copy of the row with the given _id
+func(t *Table) Get(id interface{}) (Row, bool) {
+	e, ok := t.rows[id]
+	if !ok {
+		return nil, false
+	}
+	return copyRow(e.row), true
+}
+
+// Rows returns a copy of the rows in the order they were inserted in
+func(t *Table) Rows() []Row {
+	entries := make([]*entry, 0, len(t.rows))
+	for _, e := range t.rows {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b *entry) int {
+		return a.seq - b.seq
+	})
+
+	rows := make([]Row, 0, len(entries))
+	for _, e := range entries {
+		rows = append(rows, copyRow(e.row))
+	}
+	return rows
+}
+
+// Diff returns the differences between the rows of the two engines, one per line, i.e. to check
+// that two sets of statements leave the same rows behind. Rows are matched by _id, so the order
+// they were inserted in doesn't matter, and a table without rows is the same as a missing one.
+func(e *Engine) Diff(other *Engine) []string {
+	names := make(map[string]bool)
+	for name := range e.tables {
+		names[name] = true
+	}
+	for name := range other.tables {
+		names[name] = true
+	}
+
+	var diff []string
+	for _, name := range parser.GetSortedKeys(names) {
+		rows, otherRows := getRowsByKey(e.tables[name]), getRowsByKey(other.tables[name])
+		keys := make(map[string]bool)
+		for key := range rows {
+			keys[key] = true
+		}
+		for key := range otherRows {
+			keys[key] = true
+		}
+
+		for _, key := range parser.GetSortedKeys(keys) {
+			row, ok := rows[key]
+			otherRow, otherOk := otherRows[key]
+			switch {
+			case !ok:
+				diff = append(diff, fmt.Sprintf("%s: row %s is missing, expected %v", name, key, otherRow))
+			case !otherOk:
+				diff = append(diff, fmt.Sprintf("%s: row %s is not expected, got %v", name, key, row))
+			case !reflect.DeepEqual(row, otherRow):
+				diff = append(diff, fmt.Sprintf("%s: row %s is %v, expected %v", name, key, row, otherRow))
+			}
+		}
+	}
+	return diff
+}
+
+// returns the rows of the table keyed by _id, or by their position for the rows without it
+func getRowsByKey(t *Table) map[string]Row {
+	rows := make(map[string]Row)
+	if t == nil {
+		return rows
+	}
+	for i, row := range t.Rows() {
+		if id, ok := row[idKey]; ok {
+			rows[fmt.Sprintf("%#v", id)] = row
+		} else {
+			rows[fmt.Sprintf("#%d", i + 1)] = row
+		}
+	}
+	return rows
+}
+
+// returns the type at the index, if any
+func getType(types []string, i int) string {
+	if i < len(types) {
+		return types[i]
+	}
+	return ""
+}
+
+func copyRow(row Row) Row {
+	result := make(Row, len(row))
+	for col, val := range row {
+		result[col] = val
+	}
+	return result
+}
@@ -0,0 +1,276 @@
+package replay
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/justsushant/one2n-go-bootcamp/go-mongo-oplog-parser/parser"
+)
+
+func getStatements(t *testing.T, input string, opts ...parser.Option) []parser.Statement {
+	t.Helper()
+	m := parser.NewMongoOplogParser(opts...)
+	stmts, err := m.ResolveStatements(m.Prepare(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return stmts
+}
+
+func TestEngine(t *testing.T) {
+	tt := []struct {
+		name string
+		input string
+		idempotent bool
+		opts []Option
+		exp map[string][]Row
+		expErr string
+	}{
+		{
+			name: "insert, update and delete",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51, "is_graduated": false}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith", "roll_no": 52}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe", "roll_no": 53}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena Smith", "is_graduated": true}, "d": {"roll_no": false}}}, "o2": {"_id": "1"}},
+				{"op": "d", "ns": "test.student", "o": {"_id": "2"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 54}}}, "o2": {"name": "Jane Doe"}}
+			]`,
+			exp: map[string][]Row{
+				"test.student": {
+					{"_id": "1", "name": "Selena Smith", "is_graduated": true},
+					{"_id": "3", "name": "Jane Doe", "roll_no": float64(54)},
+				},
+			},
+		},
+		{
+			name: "nested objects",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "address": [{"zip": "89799"}, {"zip": "12345"}]}}
+			]`,
+			idempotent: true,
+			opts: []Option{WithIdempotent()},
+			exp: map[string][]Row{
+				"test.student": {{"_id": "1", "name": "Selena Miller"}},
+				"test.student_address": {
+					{"_id": "a483fce5044e155981bb268b", "student__id": "1", "zip": "89799"},
+					{"_id": "bdb6909a157eb0a22bba22f9", "student__id": "1", "zip": "12345"},
+				},
+			},
+		},
+		{
+			name: "duplicate insert fails",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Smith"}}
+			]`,
+			expErr: "error: duplicate _id 1 in table test.student",
+		},
+		{
+			name: "duplicate insert upserted in idempotent mode",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Smith"}}
+			]`,
+			idempotent: true,
+			opts: []Option{WithIdempotent()},
+			exp: map[string][]Row{
+				"test.student": {{"_id": "1", "name": "Selena Smith", "roll_no": float64(51)}},
+			},
+		},
+		{
+			name: "duplicate insert replaced in lenient mode",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51}},
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Smith"}}
+			]`,
+			opts: []Option{WithLenient()},
+			exp: map[string][]Row{
+				"test.student": {{"_id": "1", "name": "Selena Smith"}},
+			},
+		},
+		{
+			// null has no type, so the column is not added for it
+			name: "update of a column not created fails",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": null}}}, "o2": {"_id": "1"}}
+			]`,
+			expErr: "error: no such column roll_no in table test.student",
+		},
+		{
+			name: "update of a column not created applied in lenient mode",
+			input: `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+				{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 51}}}, "o2": {"_id": "1"}}
+			]`,
+			opts: []Option{WithLenient()},
+			exp: map[string][]Row{
+				"test.student": {{"_id": "1", "name": "Selena Miller", "roll_no": float64(51)}},
+			},
+		},
+		{
+			name: "delete from a table not created fails",
+			input: `[
+				{"op": "d", "ns": "test.student", "o": {"_id": "1"}}
+			]`,
+			expErr: "error: no such table test.student",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var parserOpts []parser.Option
+			if tc.idempotent {
+				parserOpts = append(parserOpts, parser.WithIdempotent())
+			}
+			e := NewEngine(tc.opts...)
+			err := e.Apply(getStatements(t, tc.input, parserOpts...)...)
+			if tc.expErr != "" {
+				if err == nil || err.Error() != tc.expErr {
+					t.Fatalf("Expected error %q but got %v", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			got := make(map[string][]Row)
+			for _, table := range e.Tables() {
+				got[table.DBName + "." + table.TableName] = table.Rows()
+			}
+			if !reflect.DeepEqual(tc.exp, got) {
+				t.Errorf("Expected %v but got %v", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestEngineColumns(t *testing.T) {
+	e := NewEngine()
+	err := e.Apply(getStatements(t, `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+		{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith", "roll_no": 52}}
+	]`)...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	table := e.Table("test", "student")
+	if table == nil {
+		t.Fatalf("Expected table test.student")
+	}
+	if exp := []string{"_id", "name", "roll_no"}; !slices.Equal(exp, table.Columns()) {
+		t.Errorf("Expected columns %q but got %q", exp, table.Columns())
+	}
+	if exp := "FLOAT"; table.ColumnType("roll_no") != exp {
+		t.Errorf("Expected type %q but got %q", exp, table.ColumnType("roll_no"))
+	}
+	if row, ok := table.Get("2"); !ok || row["roll_no"] != float64(52) {
+		t.Errorf("Expected row with roll_no 52 but got %v", row)
+	}
+}
+
+func TestEngineWhereNull(t *testing.T) {
+	// the condition is = NULL in sql, which matches nothing
+	e := NewEngine()
+	err := e.Apply(getStatements(t, `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51}},
+		{"op": "i", "ns": "test.student", "o": {"_id": "2", "roll_no": 52}},
+		{"op": "d", "ns": "test.student", "o": {"name": null}}
+	]`)...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := e.Table("test", "student").Len(); got != 2 {
+		t.Errorf("Expected 2 rows but got %d", got)
+	}
+}
+
+func TestCompactionLeavesSameRows(t *testing.T) {
+	input := `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51, "phone": "+91-81254966457"}},
+		{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith", "roll_no": 52}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 53}, "d": {"phone": false}}}, "o2": {"_id": "1"}},
+		{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe", "is_graduated": false}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"is_graduated": true}}}, "o2": {"_id": "3"}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"name": "Selena Smith"}}}, "o2": {"_id": "1"}},
+		{"op": "d", "ns": "test.student", "o": {"_id": "2"}},
+		{"op": "d", "ns": "test.student", "o": {"name": "Jane Doe"}},
+		{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith", "roll_no": 54}},
+		{"op": "u", "ns": "test.student", "o": {"$v": 2, "diff": {"u": {"roll_no": 55}}}, "o2": {"_id": "2"}}
+	]`
+
+	for _, idempotent := range []bool{false, true} {
+		var parserOpts []parser.Option
+		var opts []Option
+		if idempotent {
+			parserOpts = append(parserOpts, parser.WithIdempotent())
+			opts = append(opts, WithIdempotent())
+		}
+
+		m := parser.NewMongoOplogParser(append(parserOpts, parser.WithCompaction())...)
+		stmts, err := m.ResolveStatements(m.Prepare(input))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		compacted := m.CompactStatements(stmts)
+		if len(compacted) >= len(stmts) {
+			t.Errorf("Expected fewer than %d statements but got %d", len(stmts), len(compacted))
+		}
+
+		e, exp := NewEngine(opts...), NewEngine(opts...)
+
+		// replaying in idempotent mode, the rows may already be there from a previous run
+		if idempotent {
+			seed := getStatements(t, `[
+				{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller", "roll_no": 51, "phone": "+91-81254966457"}}
+			]`, parserOpts...)
+			if err := e.Apply(seed...); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := exp.Apply(seed...); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		if err := e.Apply(compacted...); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := exp.Apply(stmts...); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if diff := e.Diff(exp); len(diff) != 0 {
+			t.Errorf("Expected same rows in idempotent mode %v but got %q", idempotent, diff)
+		}
+	}
+}
+
+func TestEngineDiff(t *testing.T) {
+	e, other := NewEngine(), NewEngine()
+	err := e.Apply(getStatements(t, `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Miller"}},
+		{"op": "i", "ns": "test.student", "o": {"_id": "2", "name": "George Smith"}}
+	]`)...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = other.Apply(getStatements(t, `[
+		{"op": "i", "ns": "test.student", "o": {"_id": "1", "name": "Selena Smith"}},
+		{"op": "i", "ns": "test.student", "o": {"_id": "3", "name": "Jane Doe"}}
+	]`)...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	exp := []string{
+		`test.student: row "1" is map[_id:1 name:Selena Miller], expected map[_id:1 name:Selena Smith]`,
+		`test.student: row "2" is not expected, got map[_id:2 name:George Smith]`,
+		`test.student: row "3" is missing, expected map[_id:3 name:Jane Doe]`,
+	}
+	if got := e.Diff(other); !slices.Equal(exp, got) {
+		t.Errorf("Expected %q but got %q", exp, got)
+	}
+}
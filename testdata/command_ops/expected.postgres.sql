@@ -0,0 +1,10 @@
+-- error: unsupported operation type "c"
+CREATE SCHEMA test;
+CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test.student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 51);
+-- error: unsupported operation type "n"
+-- error: unsupported operation type "c"
+-- error: unsupported operation type "c"
+-- error: unsupported operation type "c"
+CREATE TABLE test.teacher (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));
+INSERT INTO test.teacher (_id, name) VALUES ('5f1d7f2b9c3e4a1b2c3d4e5f', 'John Doe');
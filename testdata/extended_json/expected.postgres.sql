@@ -0,0 +1,6 @@
+CREATE SCHEMA test;
+CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, enrolled_at  VARCHAR(255), fees  FLOAT, name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test.student (_id, enrolled_at, fees, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2023-11-14T22:13:20.000Z', 1250.75, 'Selena Miller', 51);
+INSERT INTO test.student (_id, enrolled_at, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', '2023-11-14T22:13:20.000Z', 'George Smith', 21);
+UPDATE test.student SET roll_no = 22 WHERE _id = '14798c213f273a7ca2cf5174';
+DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
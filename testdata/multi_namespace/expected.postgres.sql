@@ -0,0 +1,13 @@
+CREATE SCHEMA test;
+CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test.student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 51);
+CREATE TABLE test.teacher (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), subject  VARCHAR(255));
+INSERT INTO test.teacher (_id, name, subject) VALUES ('5f1d7f2b9c3e4a1b2c3d4e5f', 'John Doe', 'Maths');
+INSERT INTO test.teacher (_id, name, subject) VALUES ('5f1d7f2b9c3e4a1b2c3d4e60', 'Mary Major', 'Physics');
+CREATE SCHEMA shop;
+CREATE TABLE shop.orders (_id  VARCHAR(255) PRIMARY KEY, customer  VARCHAR(255), paid  BOOLEAN, total  FLOAT);
+INSERT INTO shop.orders (_id, customer, paid, total) VALUES ('order-1', 'Selena Miller', false, 99.5);
+UPDATE shop.orders SET paid = true WHERE _id = 'order-1';
+UPDATE test.teacher SET subject = 'Chemistry' WHERE _id = '5f1d7f2b9c3e4a1b2c3d4e60';
+DELETE FROM test.teacher WHERE name = 'John Doe';
+INSERT INTO shop.orders (_id, customer, paid, total) VALUES ('order-2', 'George Smith', false, 12);
@@ -0,0 +1,11 @@
+CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test_student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 51);
+CREATE TABLE test_teacher (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), subject  VARCHAR(255));
+INSERT INTO test_teacher (_id, name, subject) VALUES ('5f1d7f2b9c3e4a1b2c3d4e5f', 'John Doe', 'Maths');
+INSERT INTO test_teacher (_id, name, subject) VALUES ('5f1d7f2b9c3e4a1b2c3d4e60', 'Mary Major', 'Physics');
+CREATE TABLE shop_orders (_id  VARCHAR(255) PRIMARY KEY, customer  VARCHAR(255), paid  BOOLEAN, total  FLOAT);
+INSERT INTO shop_orders (_id, customer, paid, total) VALUES ('order-1', 'Selena Miller', false, 99.5);
+UPDATE shop_orders SET paid = true WHERE _id = 'order-1';
+UPDATE test_teacher SET subject = 'Chemistry' WHERE _id = '5f1d7f2b9c3e4a1b2c3d4e60';
+DELETE FROM test_teacher WHERE name = 'John Doe';
+INSERT INTO shop_orders (_id, customer, paid, total) VALUES ('order-2', 'George Smith', false, 12);
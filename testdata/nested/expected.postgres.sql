@@ -0,0 +1,12 @@
+CREATE SCHEMA test;
+CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test.student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 51);
+CREATE TABLE test.student_address (_id  VARCHAR(255) PRIMARY KEY, line1  VARCHAR(255), student__id  VARCHAR(255), zip  VARCHAR(255));
+INSERT INTO test.student_address (_id, student__id, line1, zip) VALUES ('000000000000000000000001', '635b79e231d82a8ab1de863b', '481 Harborsburgh', '89799');
+INSERT INTO test.student_address (_id, student__id, line1, zip) VALUES ('000000000000000000000003', '635b79e231d82a8ab1de863b', '329 Flatside', '80872');
+CREATE TABLE test.student_phone (_id  VARCHAR(255) PRIMARY KEY, personal  VARCHAR(255), student__id  VARCHAR(255), work  VARCHAR(255));
+INSERT INTO test.student_phone (_id, student__id, personal, work) VALUES ('000000000000000000000005', '635b79e231d82a8ab1de863b', '7678456640', '8130097989');
+INSERT INTO test.student (_id, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', 'George Smith', 21);
+INSERT INTO test.student_address (_id, student__id, line1, zip) VALUES ('000000000000000000000007', '14798c213f273a7ca2cf5174', '12 Lakeview', '10001');
+UPDATE test.student SET roll_no = 52 WHERE _id = '635b79e231d82a8ab1de863b';
+DELETE FROM test.student WHERE _id = '14798c213f273a7ca2cf5174';
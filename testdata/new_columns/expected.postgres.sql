@@ -0,0 +1,10 @@
+CREATE SCHEMA test;
+CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));
+INSERT INTO test.student (_id, name) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller');
+ALTER TABLE test.student ADD is_graduated  BOOLEAN;
+ALTER TABLE test.student ADD roll_no  FLOAT;
+INSERT INTO test.student (_id, is_graduated, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', true, 'George Smith', 21);
+UPDATE test.student SET is_graduated = false, roll_no = 51 WHERE _id = '635b79e231d82a8ab1de863b';
+ALTER TABLE test.student ADD email  VARCHAR(255);
+INSERT INTO test.student (_id, email, name) VALUES ('3d9e2a4c8f1b7e6d5c4b3a29', 'jane@example.com', 'Jane Doe');
+UPDATE test.student SET email = 'george@example.com', is_graduated = NULL WHERE _id = '14798c213f273a7ca2cf5174';
@@ -1 +0,0 @@
-CREATE SCHEMA test;CREATE TABLE test.student (_id  VARCHAR(255) PRIMARY KEY, date_of_birth  VARCHAR(255), is_graduated  BOOLEAN, name  VARCHAR(255), roll_no  FLOAT);INSERT INTO test.student (roll_no, is_graduated, date_of_birth, _id, name) VALUES (51, false, '2000-01-30', '635b79e231d82a8ab1de863b', 'Selena Miller');UPDATE test.student SET is_graduated = true WHERE _id = '635b79e231d82a8ab1de863b';UPDATE test.student SET roll_no = NULL WHERE _id = '635b79e231d82a8ab1de863b';DELETE FROM test.student WHERE _id = '635b79e231d82a8ab1de863b';
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, date_of_birth  VARCHAR(255), is_graduated  BOOLEAN, name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test_student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', '2000-01-30', false, 'Selena Miller', 51);
+INSERT INTO test_student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', '2001-02-10', true, 'George Smith', 21);
+INSERT INTO test_student (_id, date_of_birth, is_graduated, name, roll_no) VALUES ('3d9e2a4c8f1b7e6d5c4b3a29', '1999-11-05', false, 'Jane Doe', 33);
+UPDATE test_student SET is_graduated = true, name = 'Selena Smith', roll_no = 52 WHERE _id = '635b79e231d82a8ab1de863b';
+UPDATE test_student SET roll_no = 22, date_of_birth = NULL WHERE _id = '14798c213f273a7ca2cf5174';
+DELETE FROM test_student WHERE _id = '3d9e2a4c8f1b7e6d5c4b3a29';
@@ -0,0 +1,7 @@
+CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test_student (_id, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'Selena Miller', 51);
+CREATE TABLE test_teacher (_id  VARCHAR(255) PRIMARY KEY, name  VARCHAR(255));
+INSERT INTO test_teacher (_id, name) VALUES ('5f1d7f2b9c3e4a1b2c3d4e5f', 'John Doe');
+UPDATE test_student SET roll_no = 52 WHERE _id = '635b79e231d82a8ab1de863b';
+INSERT INTO test_student (_id, name, roll_no) VALUES ('14798c213f273a7ca2cf5174', 'George Smith', 21);
+DELETE FROM test_student WHERE _id = '14798c213f273a7ca2cf5174';
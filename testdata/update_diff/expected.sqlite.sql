@@ -0,0 +1,7 @@
+CREATE TABLE test_student (_id  VARCHAR(255) PRIMARY KEY, email  VARCHAR(255), name  VARCHAR(255), roll_no  FLOAT);
+INSERT INTO test_student (_id, email, name, roll_no) VALUES ('635b79e231d82a8ab1de863b', 'selena@example.com', 'Selena Miller', 51);
+CREATE TABLE test_student_phone (_id  VARCHAR(255) PRIMARY KEY, personal  VARCHAR(255), student__id  VARCHAR(255));
+INSERT INTO test_student_phone (_id, student__id, personal) VALUES ('000000000000000000000001', '635b79e231d82a8ab1de863b', '7678456640');
+UPDATE test_student SET email = 'selena.miller@example.com' WHERE _id = '635b79e231d82a8ab1de863b';
+UPDATE test_student SET name = 'Selena M. Miller', roll_no = 52, email = NULL WHERE _id = '635b79e231d82a8ab1de863b';
+-- error: unsupported update of nested field "phone"